@@ -5,18 +5,43 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/aliadnani/claudehaus/internal/usage"
 )
 
+// Config is shared by every request, so it is read and changed through its
+// methods, which hold mu. Sessions and Projects are never indexed directly.
 type Config struct {
-	Server   ServerConfig            `json:"server"`
-	Tokens   []Token                 `json:"tokens"`
-	Sessions map[string]SessionMeta  `json:"sessions"`
-	Settings Settings                `json:"settings"`
+	mu sync.RWMutex
+
+	Server   ServerConfig           `json:"server"`
+	Tokens   []Token                `json:"tokens"`
+	Sessions map[string]SessionMeta `json:"sessions"`
+	Projects map[string]ProjectMeta `json:"projects"`
+	Settings Settings               `json:"settings"`
 }
 
 type ServerConfig struct {
-	Host string `json:"host"`
-	Port int    `json:"port"`
+	Host      string          `json:"host"`
+	Port      int             `json:"port"`
+	WebSocket WebSocketConfig `json:"websocket"`
+}
+
+// WebSocketConfig tunes the live connections to the web UI. Changes take
+// effect on restart.
+type WebSocketConfig struct {
+	// Compression negotiates permessage-deflate with clients that offer it.
+	Compression bool `json:"compression"`
+	// QueueSize is how many messages may wait to be written to one client.
+	// Zero uses the default.
+	QueueSize int `json:"queue_size"`
+	// SlowClient is what happens when a client's queue is full:
+	// "disconnect" closes the connection so the client reconnects and
+	// reloads, "drop" skips messages and tells the client to resync once
+	// it catches up.
+	SlowClient string `json:"slow_client"`
 }
 
 type Token struct {
@@ -28,25 +53,177 @@ type Token struct {
 }
 
 type SessionMeta struct {
-	Nickname string `json:"nickname"`
+	Nickname string         `json:"nickname"`
+	Context  *PinnedContext `json:"context,omitempty"`
+	// LastSeenAt is when the session was last seen by the server, used to
+	// garbage-collect metadata for sessions that will never come back.
+	LastSeenAt string `json:"last_seen_at,omitempty"`
+}
+
+// ProjectMeta holds per-project settings keyed by project directory (the
+// git root of a session's working directory, or the directory itself).
+// Sessions in a project inherit its name and settings.
+type ProjectMeta struct {
+	// Name is the display name for the project and its sessions.
+	Name string `json:"name,omitempty"`
+	// Color is a CSS color used to mark the project in the sidebar.
+	Color string `json:"color,omitempty"`
+	// ReviewTools lists tool names whose PreToolUse events block for a
+	// decision from the web UI. "*" matches every tool.
+	ReviewTools []string `json:"review_tools,omitempty"`
+	// StopHoldSeconds keeps the Stop hook open this long so a follow-up
+	// instruction can be sent from the web UI. Zero disables holding.
+	StopHoldSeconds int `json:"stop_hold_seconds,omitempty"`
+	// Context is pinned context attached to prompts in this project.
+	Context *PinnedContext `json:"context,omitempty"`
+	// AlertOnBypass raises an alert when a session in this project enters
+	// bypassPermissions mode.
+	AlertOnBypass bool `json:"alert_on_bypass,omitempty"`
+	// BudgetUSD replaces the default project budget for this project.
+	BudgetUSD float64 `json:"budget_usd,omitempty"`
+	// EventsPerSession replaces the default number of events kept for
+	// each session in this project.
+	EventsPerSession int `json:"events_per_session,omitempty"`
 }
 
 type Settings struct {
 	ApprovalTimeoutSeconds  int    `json:"approval_timeout_seconds"`
 	ApprovalTimeoutBehavior string `json:"approval_timeout_behavior"`
+	// SessionIdleSeconds and SessionStaleSeconds control when quiet sessions
+	// are marked idle and stale. Zero uses the default; negative disables.
+	SessionIdleSeconds  int `json:"session_idle_seconds"`
+	SessionStaleSeconds int `json:"session_stale_seconds"`
+	// AlertOnBypass raises an alert when any session enters
+	// bypassPermissions mode.
+	AlertOnBypass bool `json:"alert_on_bypass"`
+	// ConflictWindowSeconds is how recently another session must have
+	// modified a file for an edit to it to count as a conflict. Zero uses
+	// the default; negative disables conflict detection.
+	ConflictWindowSeconds int `json:"conflict_window_seconds"`
+	// ConflictReview holds conflicting edits for a decision from the web UI.
+	ConflictReview bool      `json:"conflict_review"`
+	Webhooks       []Webhook `json:"webhooks,omitempty"`
+	// Prices adds to or replaces the built-in model price table, keyed by
+	// model name prefix.
+	Prices map[string]usage.Price `json:"prices,omitempty"`
+	// Budgets limits spending per session, project and day.
+	Budgets Budgets `json:"budgets"`
+	// Breaker flags sessions stuck in a loop of tool calls.
+	Breaker Breaker `json:"breaker"`
+	// Redaction masks secrets in hook data.
+	Redaction Redaction `json:"redaction"`
+	// PayloadPreviewBytes is how much of a tool input or response is shown
+	// in the feed and broadcasts before it is truncated; the rest is loaded
+	// on demand. PayloadMaxBytes is the most kept at all. Zero uses the
+	// default; a negative preview size disables truncation.
+	PayloadPreviewBytes int `json:"payload_preview_bytes"`
+	PayloadMaxBytes     int `json:"payload_max_bytes"`
+	// EventsPerSession is how many events each session keeps in memory;
+	// older ones are dropped. Zero uses the default.
+	EventsPerSession int `json:"events_per_session"`
+}
+
+// Redaction configures how secrets are masked in tool input, prompts and
+// messages before they are stored, shown, logged or sent to webhooks.
+type Redaction struct {
+	// Disabled turns redaction off entirely.
+	Disabled bool `json:"disabled,omitempty"`
+	// Patterns are regular expressions for secrets the built-in detectors
+	// miss. Each match is masked.
+	Patterns []string `json:"patterns,omitempty"`
+	// KeepRawForApprovals lets a pending approval's unredacted tool input
+	// be fetched from its payload, and nowhere else, so decisions can be
+	// made on the real values.
+	KeepRawForApprovals bool `json:"keep_raw_for_approvals,omitempty"`
+}
+
+// Budgets are spending limits in US dollars. A zero limit is no budget.
+// As spending approaches and passes a limit, sessions it applies to are
+// warned, then every tool call needs approval, then tool calls are denied.
+type Budgets struct {
+	SessionUSD float64 `json:"session_usd,omitempty"`
+	ProjectUSD float64 `json:"project_usd,omitempty"`
+	DailyUSD   float64 `json:"daily_usd,omitempty"`
+	// WarnPercent, ReviewPercent and DenyPercent are the share of a budget
+	// at which each step starts. Zero uses the default; negative disables
+	// the step.
+	WarnPercent   int `json:"warn_percent"`
+	ReviewPercent int `json:"review_percent"`
+	DenyPercent   int `json:"deny_percent"`
+}
+
+// Breaker configures the circuit breaker that trips when a session repeats
+// the same tool call or calls tools too fast. Zero values use the
+// defaults; negative values disable a check.
+type Breaker struct {
+	// RepeatThreshold is how many near-identical calls within
+	// RepeatWindowSeconds trip the breaker.
+	RepeatThreshold     int `json:"repeat_threshold"`
+	RepeatWindowSeconds int `json:"repeat_window_seconds"`
+	// MaxCallsPerMinute trips the breaker when exceeded.
+	MaxCallsPerMinute int `json:"max_calls_per_minute"`
+	// Review holds every PreToolUse for a decision from the web UI while
+	// the breaker is tripped.
+	Review bool `json:"review"`
+}
+
+// Webhook is an HTTP endpoint that receives alerts as JSON POSTs.
+type Webhook struct {
+	URL string `json:"url"`
+	// Events limits the alerts sent to this webhook. Empty means all.
+	Events []string `json:"events,omitempty"`
+}
+
+// Wants reports whether the webhook subscribes to event.
+func (w Webhook) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
 }
 
+const (
+	DefaultSessionIdleSeconds    = 300
+	DefaultSessionStaleSeconds   = 1800
+	DefaultConflictWindowSeconds = 600
+
+	DefaultBudgetWarnPercent   = 80
+	DefaultBudgetReviewPercent = 100
+	DefaultBudgetDenyPercent   = 120
+
+	DefaultBreakerRepeatThreshold     = 5
+	DefaultBreakerRepeatWindowSeconds = 300
+	DefaultBreakerMaxCallsPerMinute   = 60
+
+	DefaultWebSocketQueueSize = 256
+)
+
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
 			Host: "127.0.0.1",
 			Port: 8420,
+			WebSocket: WebSocketConfig{
+				QueueSize:  DefaultWebSocketQueueSize,
+				SlowClient: "disconnect",
+			},
 		},
 		Tokens:   []Token{},
 		Sessions: make(map[string]SessionMeta),
+		Projects: make(map[string]ProjectMeta),
 		Settings: Settings{
 			ApprovalTimeoutSeconds:  300,
 			ApprovalTimeoutBehavior: "passthrough",
+			SessionIdleSeconds:      DefaultSessionIdleSeconds,
+			SessionStaleSeconds:     DefaultSessionStaleSeconds,
+			ConflictWindowSeconds:   DefaultConflictWindowSeconds,
+			Budgets: Budgets{
+				WarnPercent:   DefaultBudgetWarnPercent,
+				ReviewPercent: DefaultBudgetReviewPercent,
+				DenyPercent:   DefaultBudgetDenyPercent,
+			},
+			Breaker: Breaker{
+				RepeatThreshold:     DefaultBreakerRepeatThreshold,
+				RepeatWindowSeconds: DefaultBreakerRepeatWindowSeconds,
+				MaxCallsPerMinute:   DefaultBreakerMaxCallsPerMinute,
+			},
 		},
 	}
 }
@@ -93,11 +270,42 @@ func Load() (*Config, error) {
 	if cfg.Sessions == nil {
 		cfg.Sessions = make(map[string]SessionMeta)
 	}
+	if cfg.Projects == nil {
+		cfg.Projects = make(map[string]ProjectMeta)
+	}
 
 	return &cfg, nil
 }
 
+// CurrentSettings returns a copy of the settings.
+func (c *Config) CurrentSettings() Settings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Settings
+}
+
+// UpdateSettings applies fn to the settings and saves the config. If the
+// save fails, the settings are left as they were.
+func (c *Config) UpdateSettings(fn func(*Settings)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev := c.Settings
+	fn(&c.Settings)
+	if err := c.save(); err != nil {
+		c.Settings = prev
+		return err
+	}
+	return nil
+}
+
 func (c *Config) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.save()
+}
+
+// save writes the config to disk. The caller holds mu.
+func (c *Config) save() error {
 	dir, err := configDir()
 	if err != nil {
 		return err
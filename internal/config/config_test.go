@@ -0,0 +1,30 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestUpdateSettingsSaveFailure checks that settings that could not be
+// saved do not take effect.
+func TestUpdateSettingsSaveFailure(t *testing.T) {
+	// A home that is a file leaves nowhere to write the config.
+	home := filepath.Join(t.TempDir(), "home")
+	if err := os.WriteFile(home, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+
+	cfg := DefaultConfig()
+	before := cfg.CurrentSettings().EventsPerSession
+	err := cfg.UpdateSettings(func(s *Settings) {
+		s.EventsPerSession = before + 100
+	})
+	if err == nil {
+		t.Fatal("UpdateSettings saved the config, want an error")
+	}
+	if got := cfg.CurrentSettings().EventsPerSession; got != before {
+		t.Errorf("EventsPerSession = %d after a failed save, want %d", got, before)
+	}
+}
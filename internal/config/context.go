@@ -0,0 +1,92 @@
+package config
+
+import "time"
+
+const (
+	// ContextAlways attaches pinned context to every prompt.
+	ContextAlways = "always"
+	// ContextNext attaches pinned context to the next prompt only.
+	ContextNext = "next"
+)
+
+// PinnedContext is text attached to UserPromptSubmit hook responses as
+// additionalContext.
+type PinnedContext struct {
+	Text      string `json:"text"`
+	Mode      string `json:"mode"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// ScopedContext is pinned context resolved for a prompt, tagged with where
+// it came from ("project" or "session").
+type ScopedContext struct {
+	Scope string `json:"scope"`
+	Text  string `json:"text"`
+	Mode  string `json:"mode"`
+}
+
+// SetSessionContext pins text to a session. Empty text clears it.
+func (c *Config) SetSessionContext(sessionID, text, mode string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta := c.Sessions[sessionID]
+	meta.Context = newPinnedContext(text, mode)
+	meta.LastSeenAt = time.Now().UTC().Format(time.RFC3339)
+	c.Sessions[sessionID] = meta
+	return c.save()
+}
+
+// SetProjectContext pins text to a project. Empty text clears it.
+func (c *Config) SetProjectContext(projectDir, text, mode string) error {
+	_, err := c.UpdateProject(projectDir, func(p *ProjectMeta) {
+		p.Context = newPinnedContext(text, mode)
+	})
+	return err
+}
+
+// TakeContext returns the pinned context to attach to the next prompt in a
+// session, project first. One-shot ("next") context is cleared and saved
+// under the same lock, so it is attached to exactly one prompt.
+func (c *Config) TakeContext(sessionID, projectDir string) []ScopedContext {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []ScopedContext
+	changed := false
+
+	if meta, ok := c.Projects[projectDir]; ok && meta.Context != nil {
+		result = append(result, ScopedContext{Scope: "project", Text: meta.Context.Text, Mode: meta.Context.Mode})
+		if meta.Context.Mode == ContextNext {
+			meta.Context = nil
+			c.Projects[projectDir] = meta
+			changed = true
+		}
+	}
+
+	if meta, ok := c.Sessions[sessionID]; ok && meta.Context != nil {
+		result = append(result, ScopedContext{Scope: "session", Text: meta.Context.Text, Mode: meta.Context.Mode})
+		if meta.Context.Mode == ContextNext {
+			meta.Context = nil
+			c.Sessions[sessionID] = meta
+			changed = true
+		}
+	}
+
+	if changed {
+		_ = c.save()
+	}
+	return result
+}
+
+func newPinnedContext(text, mode string) *PinnedContext {
+	if text == "" {
+		return nil
+	}
+	if mode != ContextNext {
+		mode = ContextAlways
+	}
+	return &PinnedContext{
+		Text:      text,
+		Mode:      mode,
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+}
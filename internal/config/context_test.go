@@ -0,0 +1,54 @@
+package config
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestTakeContextConcurrent takes one-shot context while other goroutines
+// read project settings, as UserPromptSubmit and PreToolUse hooks do. Run
+// with -race.
+func TestTakeContextConcurrent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := DefaultConfig()
+	const project = "/tmp/project"
+	if _, err := cfg.UpdateProject(project, func(p *ProjectMeta) {
+		p.ReviewTools = []string{"Bash"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	const prompts = 50
+	var wg sync.WaitGroup
+	taken := make(chan int, 2*prompts)
+	for i := 0; i < prompts; i++ {
+		if err := cfg.SetProjectContext(project, "pinned", ContextNext); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			taken <- len(cfg.TakeContext("session", project))
+		}()
+		go func() {
+			defer wg.Done()
+			taken <- len(cfg.TakeContext("session", project))
+		}()
+		go func() {
+			defer wg.Done()
+			if !cfg.ReviewsTool(project, "Bash") {
+				t.Error("ReviewsTool(Bash) = false, want true")
+			}
+		}()
+		wg.Wait()
+	}
+	close(taken)
+
+	total := 0
+	for n := range taken {
+		total += n
+	}
+	if total != prompts {
+		t.Errorf("one-shot context taken %d times, want %d", total, prompts)
+	}
+}
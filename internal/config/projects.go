@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// ProjectRoot returns the project directory for a working directory: the
+// nearest enclosing git root (a directory containing .git, including
+// worktrees where .git is a file), or cwd itself outside a repository.
+func ProjectRoot(cwd string) string {
+	if cwd == "" {
+		return ""
+	}
+	dir := filepath.Clean(cwd)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return filepath.Clean(cwd)
+		}
+		dir = parent
+	}
+}
+
+// ProjectName returns the display name for a project: its configured name,
+// or the base name of its directory.
+func (c *Config) ProjectName(projectDir string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if meta, ok := c.Projects[projectDir]; ok && meta.Name != "" {
+		return meta.Name
+	}
+	return filepath.Base(projectDir)
+}
+
+// ReviewsTool reports whether PreToolUse events for toolName in projectDir
+// should be held for a remote decision.
+func (c *Config) ReviewsTool(projectDir, toolName string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	meta, ok := c.Projects[projectDir]
+	if !ok {
+		return false
+	}
+	return slices.Contains(meta.ReviewTools, "*") || slices.Contains(meta.ReviewTools, toolName)
+}
+
+// StopHold returns how long Stop hooks in projectDir are held open.
+func (c *Config) StopHold(projectDir string) time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Duration(c.Projects[projectDir].StopHoldSeconds) * time.Second
+}
+
+// AlertsOnBypass reports whether a session in projectDir entering
+// bypassPermissions mode should raise an alert.
+func (c *Config) AlertsOnBypass(projectDir string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Settings.AlertOnBypass || c.Projects[projectDir].AlertOnBypass
+}
+
+// ProjectBudget returns the spending limit for projectDir in US dollars,
+// zero if it has none.
+func (c *Config) ProjectBudget(projectDir string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if budget := c.Projects[projectDir].BudgetUSD; budget > 0 {
+		return budget
+	}
+	return c.Settings.Budgets.ProjectUSD
+}
+
+// Project returns the settings for projectDir, empty if it has none.
+func (c *Config) Project(projectDir string) ProjectMeta {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Projects[projectDir]
+}
+
+// UpdateProject applies fn to the settings for projectDir and saves the config.
+func (c *Config) UpdateProject(projectDir string, fn func(*ProjectMeta)) (ProjectMeta, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta := c.Projects[projectDir]
+	fn(&meta)
+	c.Projects[projectDir] = meta
+	return meta, c.save()
+}
+
+func (c *Config) ListProjects() map[string]ProjectMeta {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make(map[string]ProjectMeta, len(c.Projects))
+	for dir, meta := range c.Projects {
+		result[dir] = meta
+	}
+	return result
+}
+
+// TouchSessionMeta records that a session was seen now. It only writes the
+// config if the session already has metadata worth keeping.
+func (c *Config) TouchSessionMeta(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta, ok := c.Sessions[sessionID]
+	if !ok {
+		return
+	}
+	meta.LastSeenAt = time.Now().UTC().Format(time.RFC3339)
+	c.Sessions[sessionID] = meta
+	_ = c.save()
+}
+
+// Session returns the metadata kept for a session.
+func (c *Config) Session(sessionID string) (SessionMeta, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	meta, ok := c.Sessions[sessionID]
+	return meta, ok
+}
+
+// SetSessionNickname names a session and saves the config.
+func (c *Config) SetSessionNickname(sessionID, nickname string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	meta := c.Sessions[sessionID]
+	meta.Nickname = nickname
+	meta.LastSeenAt = time.Now().UTC().Format(time.RFC3339)
+	c.Sessions[sessionID] = meta
+	return c.save()
+}
+
+// PruneSessions drops metadata for sessions not seen within maxAge, and
+// entries with nothing left in them. Entries from before LastSeenAt was
+// recorded are stamped now, so they get one full maxAge before removal.
+// It returns the number of entries removed.
+func (c *Config) PruneSessions(maxAge time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UTC()
+	removed := 0
+	changed := false
+	for id, meta := range c.Sessions {
+		if meta.Nickname == "" && meta.Context == nil {
+			delete(c.Sessions, id)
+			removed++
+			continue
+		}
+		seen, err := time.Parse(time.RFC3339, meta.LastSeenAt)
+		if err != nil {
+			meta.LastSeenAt = now.Format(time.RFC3339)
+			c.Sessions[id] = meta
+			changed = true
+			continue
+		}
+		if now.Sub(seen) > maxAge {
+			delete(c.Sessions, id)
+			removed++
+		}
+	}
+	if removed > 0 || changed {
+		_ = c.save()
+	}
+	return removed
+}
@@ -35,9 +35,11 @@ func (c *Config) CreateToken(name string) (string, error) {
 		LastUsedAt: "",
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Tokens = append(c.Tokens, token)
 
-	if err := c.Save(); err != nil {
+	if err := c.save(); err != nil {
 		return "", fmt.Errorf("saving config: %w", err)
 	}
 
@@ -45,22 +47,39 @@ func (c *Config) CreateToken(name string) (string, error) {
 }
 
 func (c *Config) ValidateToken(value string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	// Simple direct comparison - tokens are stored in plaintext in the config file
 	for i := range c.Tokens {
 		if c.Tokens[i].Value == value {
 			c.Tokens[i].LastUsedAt = time.Now().UTC().Format(time.RFC3339)
-			_ = c.Save()
+			_ = c.save()
 			return true
 		}
 	}
 	return false
 }
 
+// TokenName returns the name of the token with the given value, or "" if
+// there is none.
+func (c *Config) TokenName(value string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, t := range c.Tokens {
+		if t.Value == value {
+			return t.Name
+		}
+	}
+	return ""
+}
+
 func (c *Config) RevokeToken(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	for i, t := range c.Tokens {
 		if t.ID == id {
 			c.Tokens = append(c.Tokens[:i], c.Tokens[i+1:]...)
-			_ = c.Save()
+			_ = c.save()
 			return true
 		}
 	}
@@ -68,13 +87,15 @@ func (c *Config) RevokeToken(id string) bool {
 }
 
 func (c *Config) ListTokens() []Token {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	result := make([]Token, len(c.Tokens))
 	copy(result, c.Tokens)
 	return result
 }
 
 func (c *Config) EnsureDefaultToken() (string, bool, error) {
-	if len(c.Tokens) > 0 {
+	if len(c.ListTokens()) > 0 {
 		return "", false, nil
 	}
 
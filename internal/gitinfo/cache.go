@@ -0,0 +1,78 @@
+package gitinfo
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// readTimeout bounds a single refresh so a slow repository cannot stall
+// page renders.
+const readTimeout = 5 * time.Second
+
+// unusedTTL is how long an entry nobody asked for is kept, so sessions
+// that ended long ago don't hold on to their git state.
+const unusedTTL = time.Hour
+
+// Cache holds the most recent git Info per session. Entries are refreshed
+// on demand once older than the TTL, or by Refresh, and dropped once
+// unused for unusedTTL.
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	info    *Info
+	fetched time.Time
+	used    time.Time
+}
+
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// Get returns git information for the session key working in dir, reading
+// it if the cached copy is missing or expired. It returns nil when dir is
+// not a git repository.
+func (c *Cache) Get(key, dir string, since time.Time) *Info {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok {
+		entry.used = time.Now()
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetched) < c.ttl {
+		return entry.info
+	}
+	return c.Refresh(key, dir, since)
+}
+
+// Refresh reads git information for the session key and caches it.
+func (c *Cache) Refresh(key, dir string, since time.Time) *Info {
+	ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+	defer cancel()
+
+	info, err := Read(ctx, dir, since)
+	if err != nil {
+		slog.Debug("git info unavailable", "session_id", key, "dir", dir, "error", err)
+		info = nil
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{info: info, fetched: now, used: now}
+	for k, entry := range c.entries {
+		if now.Sub(entry.used) > unusedTTL {
+			delete(c.entries, k)
+		}
+	}
+	return info
+}
@@ -0,0 +1,136 @@
+// Package gitinfo reads git state for a session's working directory by
+// running the local git binary.
+package gitinfo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCommits bounds the number of session commits reported.
+const maxCommits = 20
+
+// Info is a snapshot of the git state of a working directory.
+type Info struct {
+	Branch string `json:"branch"`
+	// Worktree is the name of the linked worktree, empty for the main one.
+	Worktree string `json:"worktree,omitempty"`
+	Upstream string `json:"upstream,omitempty"`
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+	// Modified lists changed, staged and untracked files.
+	Modified []FileStatus `json:"modified"`
+	// Commits lists commits made since the session started, newest first.
+	Commits   []Commit  `json:"commits"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FileStatus is a file with uncommitted changes. Status is the two-letter
+// porcelain code, e.g. " M", "A ", "??".
+type FileStatus struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+type Commit struct {
+	Hash    string    `json:"hash"`
+	Subject string    `json:"subject"`
+	Author  string    `json:"author"`
+	Time    time.Time `json:"time"`
+}
+
+// Read collects git information for dir, including commits made since
+// since. It returns an error if dir is not inside a git repository or git
+// is not available.
+func Read(ctx context.Context, dir string, since time.Time) (*Info, error) {
+	out, err := run(ctx, dir, "rev-parse", "--git-dir", "--git-common-dir")
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Modified: []FileStatus{}, Commits: []Commit{}, UpdatedAt: time.Now()}
+
+	dirs := strings.Split(strings.TrimSpace(out), "\n")
+	if len(dirs) == 2 && filepath.Clean(dirs[0]) != filepath.Clean(dirs[1]) {
+		// Linked worktrees keep their git dir at <common>/worktrees/<name>.
+		info.Worktree = filepath.Base(dirs[0])
+	}
+
+	out, err = run(ctx, dir, "status", "--porcelain=v2", "--branch")
+	if err != nil {
+		return nil, err
+	}
+	parseStatus(out, info)
+
+	out, err = run(ctx, dir, "log", "-n", strconv.Itoa(maxCommits),
+		"--since="+since.Format(time.RFC3339), "--format=%h%x1f%s%x1f%an%x1f%aI")
+	if err != nil {
+		// A branch without commits has no log; that is not an error.
+		return info, nil
+	}
+	for line := range strings.SplitSeq(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		t, _ := time.Parse(time.RFC3339, fields[3])
+		info.Commits = append(info.Commits, Commit{Hash: fields[0], Subject: fields[1], Author: fields[2], Time: t})
+	}
+	return info, nil
+}
+
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// parseStatus fills branch and file information from the output of
+// git status --porcelain=v2 --branch.
+func parseStatus(out string, info *Info) {
+	for line := range strings.SplitSeq(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			info.Branch = strings.TrimPrefix(line, "# branch.head ")
+		case strings.HasPrefix(line, "# branch.oid ") && info.Branch == "(detached)":
+			oid := strings.TrimPrefix(line, "# branch.oid ")
+			info.Branch = "detached at " + oid[:min(7, len(oid))]
+		case strings.HasPrefix(line, "# branch.upstream "):
+			info.Upstream = strings.TrimPrefix(line, "# branch.upstream ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			fmt.Sscanf(strings.TrimPrefix(line, "# branch.ab "), "+%d -%d", &info.Ahead, &info.Behind)
+		case strings.HasPrefix(line, "1 "):
+			// 1 XY sub mH mI mW hH hI path
+			if f := strings.SplitN(line, " ", 9); len(f) == 9 {
+				info.Modified = append(info.Modified, FileStatus{Path: f[8], Status: dotsToSpaces(f[1])})
+			}
+		case strings.HasPrefix(line, "2 "):
+			// 2 XY sub mH mI mW hH hI Xscore path<TAB>origPath
+			if f := strings.SplitN(line, " ", 10); len(f) == 10 {
+				path, _, _ := strings.Cut(f[9], "\t")
+				info.Modified = append(info.Modified, FileStatus{Path: path, Status: dotsToSpaces(f[1])})
+			}
+		case strings.HasPrefix(line, "u "):
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			if f := strings.SplitN(line, " ", 11); len(f) == 11 {
+				info.Modified = append(info.Modified, FileStatus{Path: f[10], Status: "UU"})
+			}
+		case strings.HasPrefix(line, "? "):
+			info.Modified = append(info.Modified, FileStatus{Path: line[2:], Status: "??"})
+		}
+	}
+}
+
+func dotsToSpaces(xy string) string {
+	return strings.ReplaceAll(xy, ".", " ")
+}
@@ -12,18 +12,26 @@ type ApprovalStore struct {
 }
 
 type PendingApproval struct {
-	ID           string          `json:"id"`
-	SessionID    string          `json:"session_id"`
-	CreatedAt    time.Time       `json:"created_at"`
-	ToolName     string          `json:"tool_name"`
-	ToolInput    json.RawMessage `json:"tool_input"`
-	Prompt       string          `json:"prompt"`
+	ID        string          `json:"id"`
+	SessionID string          `json:"session_id"`
+	EventName string          `json:"event_name"`
+	CreatedAt time.Time       `json:"created_at"`
+	ToolName  string          `json:"tool_name"`
+	ToolInput json.RawMessage `json:"tool_input"`
+	Prompt    string          `json:"prompt"`
+	// Warning is shown with the request, e.g. a file conflict with
+	// another session.
+	Warning string `json:"warning,omitempty"`
+	// RawToolInput is the unredacted tool input, kept only while the
+	// approval is pending and only if configured.
+	RawToolInput json.RawMessage `json:"-"`
 	ResponseChan chan Decision   `json:"-"`
 }
 
 type Decision struct {
-	Behavior string `json:"behavior"`
-	Message  string `json:"message,omitempty"`
+	Behavior string            `json:"behavior"`
+	Message  string            `json:"message,omitempty"`
+	Answers  map[string]string `json:"answers,omitempty"`
 }
 
 func NewApprovalStore() *ApprovalStore {
@@ -0,0 +1,50 @@
+package hooks
+
+import (
+	"sync"
+	"time"
+)
+
+const maxContextChanges = 500
+
+// ContextChange is an audit record of a pinned context edit.
+type ContextChange struct {
+	Timestamp time.Time `json:"timestamp"`
+	Scope     string    `json:"scope"`
+	Target    string    `json:"target"`
+	Text      string    `json:"text"`
+	Mode      string    `json:"mode,omitempty"`
+	ChangedBy string    `json:"changed_by"`
+}
+
+// ContextAudit keeps recent pinned context edits, newest last.
+type ContextAudit struct {
+	mu      sync.RWMutex
+	changes []ContextChange
+}
+
+func NewContextAudit() *ContextAudit {
+	return &ContextAudit{}
+}
+
+func (a *ContextAudit) Record(change ContextChange) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.changes = append(a.changes, change)
+	if len(a.changes) > maxContextChanges {
+		a.changes = append(a.changes[:0:0], a.changes[len(a.changes)-maxContextChanges:]...)
+	}
+}
+
+// List returns changes for target (all targets if empty), newest first.
+func (a *ContextAudit) List(target string) []ContextChange {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	result := make([]ContextChange, 0)
+	for i := len(a.changes) - 1; i >= 0; i-- {
+		if target == "" || a.changes[i].Target == target {
+			result = append(result, a.changes[i])
+		}
+	}
+	return result
+}
@@ -1,80 +1,566 @@
 package hooks
 
 import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
+// Event is something that happened in a session: a hook Claude Code
+// called, or something Claudehaus did about it.
 type Event struct {
-	ID         string
-	SessionID  string
-	Timestamp  string
-	EventName  string
-	ToolName   string
-	ToolInput  string
-	Detail     string
+	// ID is unique and sorts by creation time.
+	ID string `json:"id"`
+	// Seq orders events in the store; later events have higher values. It
+	// is the cursor for paging through Query results.
+	Seq       uint64 `json:"seq"`
+	SessionID string `json:"session_id"`
+	// AgentID is the subagent the event is attributed to, empty for the
+	// parent session.
+	AgentID string `json:"agent_id,omitempty"`
+	// Time is when the hook was received, or when Claudehaus recorded the
+	// event.
+	Time      time.Time `json:"time"`
+	EventName string    `json:"event"`
+	ToolName  string    `json:"tool_name,omitempty"`
+	ToolUseID string    `json:"tool_use_id,omitempty"`
+	// ToolInput and ToolResponse are JSON. When Truncated, they are JSON
+	// strings holding a preview of the original.
+	ToolInput json.RawMessage `json:"tool_input,omitempty"`
+	// ToolResponse is what the tool returned, for PostToolUse events.
+	ToolResponse   json.RawMessage `json:"tool_response,omitempty"`
+	Detail         string          `json:"detail,omitempty"`
+	PermissionMode string          `json:"permission_mode,omitempty"`
+	Cwd            string          `json:"cwd,omitempty"`
+	// Latency is the time from the PreToolUse of a tool call to its
+	// PostToolUse, set on the latter.
+	Latency time.Duration `json:"latency_ns,omitempty"`
+	// Raw is the hook's request body, with secrets masked. Large bodies
+	// are kept only in the event's Payload.
+	Raw json.RawMessage `json:"raw,omitempty"`
+	// Truncated is set when ToolInput or ToolResponse hold only a preview;
+	// the full values, PayloadSize bytes together, are available from
+	// Payload.
+	Truncated   bool `json:"truncated,omitempty"`
+	PayloadSize int  `json:"payload_size,omitempty"`
 }
 
+// NewEvent returns the event for a hook call, from its decoded input and
+// request body.
+func NewEvent(name string, input HookInput, raw json.RawMessage) Event {
+	return Event{
+		SessionID:      input.SessionID,
+		EventName:      name,
+		ToolName:       input.ToolName,
+		ToolUseID:      input.ToolUseID,
+		ToolInput:      input.ToolInput,
+		ToolResponse:   input.ToolResponse,
+		PermissionMode: input.PermissionMode,
+		Cwd:            input.Cwd,
+		Raw:            raw,
+	}
+}
+
+// JSONText returns a JSON value for display: strings unquoted, anything
+// else as written.
+func JSONText(value json.RawMessage) string {
+	var s string
+	if len(value) > 0 && value[0] == '"' && json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	return string(value)
+}
+
+// InputText is the tool input, or its preview, for display.
+func (e Event) InputText() string {
+	return JSONText(e.ToolInput)
+}
+
+// ResponseText is the tool response, or its preview, for display.
+func (e Event) ResponseText() string {
+	return JSONText(e.ToolResponse)
+}
+
+// EventQuery selects events from the store. Zero fields match everything.
+type EventQuery struct {
+	// SessionIDs, EventNames and ToolNames match any of their values.
+	// Event and tool names are compared case-insensitively.
+	SessionIDs []string
+	EventNames []string
+	ToolNames  []string
+	// Since and Until bound the event time, inclusive.
+	Since time.Time
+	Until time.Time
+	// Text matches events whose detail, tool input or tool response
+	// contains it, ignoring case. Truncated events are matched on their
+	// full payload.
+	Text string
+	// Before returns only events older than this Seq, continuing a
+	// previous page.
+	Before uint64
+	Limit  int
+}
+
+// Payload is the full tool input, response and hook body of an event whose
+// preview was truncated. Values larger than the store's maximum are cut to
+// it, or for Raw dropped, and marked Clipped.
+type Payload struct {
+	ToolInput    string          `json:"tool_input"`
+	ToolResponse string          `json:"tool_response,omitempty"`
+	InputSize    int             `json:"tool_input_size"`
+	ResponseSize int             `json:"tool_response_size"`
+	Raw          json.RawMessage `json:"raw,omitempty"`
+	Clipped      bool            `json:"clipped"`
+}
+
+// Default payload limits, in bytes.
+const (
+	DefaultPreviewBytes = 2 << 10
+	DefaultPayloadBytes = 256 << 10
+)
+
+// DefaultSessionEvents is how many events are kept per session unless
+// configured otherwise.
+const DefaultSessionEvents = 500
+
+// Store-wide limits, which bound memory however many sessions there have
+// been. Past either, the oldest events of any session are dropped.
+const (
+	maxStoreEvents       = 10000
+	maxStorePayloadBytes = 64 << 20
+)
+
+// EventStore keeps the latest events of each session in a ring buffer of
+// its own, so a busy session cannot push out the history of quiet ones.
+// Across sessions, the oldest events go first once the store is full.
 type EventStore struct {
-	mu     sync.RWMutex
-	events []Event
+	mu       sync.RWMutex
+	sessions map[string]*sessionEvents
+	// ids locates events by ID for Payload.
+	ids map[string]eventRef
+	// payloads holds the full content of truncated events by event ID.
+	payloads map[string]*Payload
+	// count is the events held and payloadSize the size of payloads,
+	// kept under maxEvents and maxPayloadSize.
+	count          int
+	payloadSize    int
+	maxEvents      int
+	maxPayloadSize int
+	limit          int
+	previewBytes   int
+	payloadBytes   int
+	seq            uint64
+	onAdd          func(Event)
+}
+
+type eventRef struct {
+	sessionID string
+	seq       uint64
 }
 
 func NewEventStore() *EventStore {
 	return &EventStore{
-		events: make([]Event, 0, 100),
+		sessions:       make(map[string]*sessionEvents),
+		ids:            make(map[string]eventRef),
+		payloads:       make(map[string]*Payload),
+		maxEvents:      maxStoreEvents,
+		maxPayloadSize: maxStorePayloadBytes,
+		limit:          DefaultSessionEvents,
+		previewBytes:   DefaultPreviewBytes,
+		payloadBytes:   DefaultPayloadBytes,
+	}
+}
+
+// SetPayloadLimits sets how much of a tool input or response is kept inline
+// as a preview and how much is kept at most. A negative preview disables
+// truncation.
+func (s *EventStore) SetPayloadLimits(previewBytes, payloadBytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.previewBytes = previewBytes
+	s.payloadBytes = payloadBytes
+}
+
+// Truncate cuts s to at most n bytes without splitting a UTF-8 character.
+// It reports whether anything was cut. A negative n keeps s whole.
+func Truncate(s string, n int) (string, bool) {
+	if n < 0 || len(s) <= n {
+		return s, false
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], true
+}
+
+// FormatBytes renders a size such as 12.3 KB.
+func FormatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
 	}
 }
 
-func (s *EventStore) Add(event Event) {
+// shrink replaces the event's tool input and response with previews if
+// either is over the preview size, and moves a large hook body out of the
+// event, keeping the full values as its payload. Callers hold s.mu.
+func (s *EventStore) shrink(event *Event) {
+	limit := s.previewBytes
+	if limit < 0 || (len(event.ToolInput) <= limit && len(event.ToolResponse) <= limit && len(event.Raw) <= limit) {
+		return
+	}
+	input, response := event.InputText(), event.ResponseText()
+	payload := &Payload{InputSize: len(input), ResponseSize: len(response)}
+	var inputClipped, responseClipped bool
+	payload.ToolInput, inputClipped = Truncate(input, s.payloadBytes)
+	payload.ToolResponse, responseClipped = Truncate(response, s.payloadBytes)
+	payload.Clipped = inputClipped || responseClipped
+	if s.payloadBytes < 0 || len(event.Raw) <= s.payloadBytes {
+		payload.Raw = event.Raw
+	} else {
+		payload.Clipped = true
+	}
+	s.payloads[event.ID] = payload
+	s.payloadSize += payload.size()
+	event.Raw = nil
+
+	if len(event.ToolInput) <= limit && len(event.ToolResponse) <= limit {
+		return
+	}
+	event.ToolInput = previewJSON(event.ToolInput, input, limit)
+	event.ToolResponse = previewJSON(event.ToolResponse, response, limit)
+	event.Truncated = true
+	event.PayloadSize = payload.InputSize + payload.ResponseSize
+}
+
+// size is how much memory the payload's content takes.
+func (p *Payload) size() int {
+	return len(p.ToolInput) + len(p.ToolResponse) + len(p.Raw)
+}
+
+// previewJSON returns value if it fits in limit bytes, or else a JSON
+// string holding the first limit bytes of its text.
+func previewJSON(value json.RawMessage, text string, limit int) json.RawMessage {
+	if len(value) <= limit {
+		return value
+	}
+	preview, _ := Truncate(text, limit)
+	encoded, _ := json.Marshal(preview)
+	return encoded
+}
+
+// SetLimit sets how many events are kept for each session without a limit
+// of its own. Zero or less uses the default. Sessions over the new limit
+// lose their oldest events.
+func (s *EventStore) SetLimit(limit int) {
+	if limit <= 0 {
+		limit = DefaultSessionEvents
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+	for _, r := range s.sessions {
+		s.resize(r)
+	}
+}
+
+// SetSessionLimit sets how many events are kept for one session. Zero or
+// less follows the store's limit.
+func (s *EventStore) SetSessionLimit(sessionID string, limit int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.events = append(s.events, event)
-
-	// Keep only the last 500 events per session
-	if len(s.events) > 500 {
-		// Remove oldest events for the same session
-		count := 0
-		newEvents := make([]Event, 0, len(s.events)-1)
-		for _, e := range s.events {
-			if e.SessionID != event.SessionID || count > 0 {
-				newEvents = append(newEvents, e)
-			} else {
-				count++
+	r, ok := s.sessions[sessionID]
+	if !ok {
+		if limit <= 0 {
+			return
+		}
+		r = newSessionEvents()
+		s.sessions[sessionID] = r
+	}
+	r.limit = max(limit, 0)
+	s.resize(r)
+}
+
+// capacity is how many events a session keeps. Callers hold s.mu.
+func (s *EventStore) capacity(r *sessionEvents) int {
+	if r.limit > 0 {
+		return r.limit
+	}
+	return s.limit
+}
+
+// resize drops the events a session keeps beyond its capacity. Callers
+// hold s.mu.
+func (s *EventStore) resize(r *sessionEvents) {
+	if r.len() <= s.capacity(r) {
+		return
+	}
+	for _, e := range r.resize(s.capacity(r)) {
+		s.evict(e)
+	}
+}
+
+// evict forgets an event dropped from its session's buffer. Callers hold
+// s.mu.
+func (s *EventStore) evict(e Event) {
+	s.count--
+	if payload, ok := s.payloads[e.ID]; ok {
+		s.payloadSize -= payload.size()
+		delete(s.payloads, e.ID)
+	}
+	delete(s.ids, e.ID)
+}
+
+// trim drops the oldest events across sessions until the store is within
+// its limits, and forgets sessions left without events. Callers hold s.mu.
+func (s *EventStore) trim() {
+	for s.count > s.maxEvents || s.payloadSize > s.maxPayloadSize {
+		var oldestID string
+		var oldest *sessionEvents
+		for id, r := range s.sessions {
+			if r.len() > 0 && (oldest == nil || r.at(0).Seq < oldest.at(0).Seq) {
+				oldestID, oldest = id, r
 			}
 		}
-		s.events = newEvents
+		if oldest == nil {
+			return
+		}
+		s.evict(oldest.shift())
+		// A session with a limit of its own keeps its buffer for it.
+		if oldest.len() == 0 && oldest.limit == 0 {
+			delete(s.sessions, oldestID)
+		}
+	}
+}
+
+// OnAdd sets a function called with each event added, before its payload
+// is truncated.
+func (s *EventStore) OnAdd(fn func(Event)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onAdd = fn
+}
+
+// Add stores an event and returns its ID. The ID, Seq and, if unset, Time
+// are filled in, and for a PostToolUse the latency since its PreToolUse.
+func (s *EventStore) Add(event Event) string {
+	if event.ID == "" {
+		event.ID = generateEventID()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	s.seq++
+	event.Seq = s.seq
+	r, ok := s.sessions[event.SessionID]
+	if !ok {
+		r = newSessionEvents()
+		s.sessions[event.SessionID] = r
+	}
+	if pre, ok := preToolUse(r, event); ok {
+		event.Latency = event.Time.Sub(pre.Time)
+	}
+	full := event
+	onAdd := s.onAdd
+	s.shrink(&event)
+	s.count++
+	if evicted, ok := r.push(event, s.capacity(r)); ok {
+		s.evict(evicted)
+	}
+	s.ids[event.ID] = eventRef{sessionID: event.SessionID, seq: event.Seq}
+	s.trim()
+	s.mu.Unlock()
+
+	if onAdd != nil {
+		onAdd(full)
 	}
+	return event.ID
 }
 
+// preToolUse finds the PreToolUse of the tool call a PostToolUse completes.
+// Callers hold s.mu.
+func preToolUse(r *sessionEvents, post Event) (Event, bool) {
+	if post.ToolUseID == "" || (post.EventName != "PostToolUse" && post.EventName != "PostToolUseFailure") {
+		return Event{}, false
+	}
+	seq, ok := r.pending[post.ToolUseID]
+	if !ok {
+		return Event{}, false
+	}
+	i, ok := r.find(seq)
+	if !ok {
+		return Event{}, false
+	}
+	return *r.at(i), true
+}
+
+// GetBySession returns a session's latest events, newest first, at most
+// limit of them, or all of them if limit is zero or less.
 func (s *EventStore) GetBySession(sessionID string, limit int) []Event {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	r, ok := s.sessions[sessionID]
+	if !ok {
+		return []Event{}
+	}
+	n := r.len()
+	if limit > 0 {
+		n = min(n, limit)
+	}
+	result := make([]Event, 0, n)
+	for i := r.len() - 1; i >= r.len()-n; i-- {
+		result = append(result, *r.at(i))
+	}
+	return result
+}
+
+// Query returns the events matching q, newest first, and whether older
+// matching events remain beyond q.Limit. It walks the buffers of the
+// sessions queried from q.Before back, merging them by Seq.
+func (s *EventStore) Query(q EventQuery) ([]Event, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// cursors[i] is how many events of buffers[i] are left to visit; the
+	// next is at(cursors[i]-1).
+	var buffers []*sessionEvents
+	var cursors []int
+	visit := func(r *sessionEvents) {
+		if n := r.before(q.Before); n > 0 {
+			buffers = append(buffers, r)
+			cursors = append(cursors, n)
+		}
+	}
+	if len(q.SessionIDs) > 0 {
+		for _, id := range q.SessionIDs {
+			if r, ok := s.sessions[id]; ok && !slices.Contains(buffers, r) {
+				visit(r)
+			}
+		}
+	} else {
+		for _, r := range s.sessions {
+			visit(r)
+		}
+	}
+
+	text := strings.ToLower(q.Text)
 	result := make([]Event, 0)
-	for i := len(s.events) - 1; i >= 0; i-- {
-		if s.events[i].SessionID == sessionID {
-			result = append(result, s.events[i])
-			if len(result) >= limit {
-				break
+	for {
+		next := -1
+		for i, r := range buffers {
+			if cursors[i] > 0 && (next < 0 || r.at(cursors[i]-1).Seq > buffers[next].at(cursors[next]-1).Seq) {
+				next = i
 			}
 		}
+		if next < 0 {
+			return result, false
+		}
+		cursors[next]--
+		e := buffers[next].at(cursors[next])
+		switch {
+		case len(q.EventNames) > 0 && !containsFold(q.EventNames, e.EventName),
+			len(q.ToolNames) > 0 && !containsFold(q.ToolNames, e.ToolName),
+			!q.Since.IsZero() && e.Time.Before(q.Since),
+			!q.Until.IsZero() && e.Time.After(q.Until),
+			text != "" && !s.containsText(*e, text):
+			continue
+		}
+		if q.Limit > 0 && len(result) == q.Limit {
+			return result, true
+		}
+		result = append(result, *e)
 	}
-	return result
 }
 
-func (s *EventStore) AddEvent(sessionID, eventName, toolName, toolInput, detail string) {
-	s.Add(Event{
-		ID:        generateEventID(),
+func containsFold(values []string, s string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, s) })
+}
+
+// containsText reports whether the event's detail or content contains the
+// lowercased text. Callers hold s.mu.
+func (s *EventStore) containsText(e Event, text string) bool {
+	input, response := e.InputText(), e.ResponseText()
+	if payload, ok := s.payloads[e.ID]; ok && e.Truncated {
+		input, response = payload.ToolInput, payload.ToolResponse
+	}
+	for _, field := range []string{e.Detail, input, response} {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the event with the given ID as stored, truncated if it was
+// too large.
+func (s *EventStore) Get(id string) (Event, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.get(id)
+}
+
+// get finds an event by ID. Callers hold s.mu.
+func (s *EventStore) get(id string) (Event, bool) {
+	ref, ok := s.ids[id]
+	if !ok {
+		return Event{}, false
+	}
+	r := s.sessions[ref.sessionID]
+	i, ok := r.find(ref.seq)
+	if !ok {
+		return Event{}, false
+	}
+	return *r.at(i), true
+}
+
+// Payload returns the event with the given ID and its full payload. For
+// events that were not truncated the payload is the event's own content.
+func (s *EventStore) Payload(id string) (Event, Payload, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.get(id)
+	if !ok {
+		return Event{}, Payload{}, false
+	}
+	if payload, ok := s.payloads[id]; ok {
+		return e, *payload, true
+	}
+	input, response := e.InputText(), e.ResponseText()
+	return e, Payload{
+		ToolInput:    input,
+		ToolResponse: response,
+		InputSize:    len(input),
+		ResponseSize: len(response),
+		Raw:          e.Raw,
+	}, true
+}
+
+// AddEvent records an event Claudehaus generated rather than a hook, such
+// as a budget alert, and returns its ID.
+func (s *EventStore) AddEvent(sessionID, eventName, detail string) string {
+	return s.Add(Event{
 		SessionID: sessionID,
-		Timestamp: time.Now().Format("15:04:05"),
 		EventName: eventName,
-		ToolName:  toolName,
-		ToolInput: toolInput,
 		Detail:    detail,
 	})
 }
 
+var eventCounter atomic.Uint32
+
+// generateEventID returns a unique ID that sorts by creation time: the time
+// in nanoseconds and a per-process counter, in hex.
 func generateEventID() string {
-	return time.Now().Format("20060102150405.999999999")
+	return fmt.Sprintf("%016x%04x", time.Now().UnixNano(), eventCounter.Add(1)&0xffff)
 }
@@ -0,0 +1,151 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// benchSessions is how many sessions record events at once. Full, they
+// take up the whole store.
+const benchSessions = 20
+
+// newBenchStore returns a store whose sessions are already full, so every
+// Add evicts.
+func newBenchStore() *EventStore {
+	s := NewEventStore()
+	for i := 0; i < benchSessions; i++ {
+		for j := 0; j < DefaultSessionEvents; j++ {
+			s.Add(benchEvent(fmt.Sprintf("session-%d", i), j))
+		}
+	}
+	return s
+}
+
+func benchEvent(sessionID string, n int) Event {
+	name := "PreToolUse"
+	if n%2 == 1 {
+		name = "PostToolUse"
+	}
+	return Event{
+		SessionID: sessionID,
+		EventName: name,
+		ToolName:  "Bash",
+		ToolUseID: fmt.Sprintf("tool-%d", n/2),
+		ToolInput: json.RawMessage(`{"command":"go test ./..."}`),
+	}
+}
+
+func TestEventStoreLimits(t *testing.T) {
+	t.Run("events", func(t *testing.T) {
+		s := NewEventStore()
+		s.maxEvents = 10
+		var first string
+		for i := 0; i < 25; i++ {
+			id := s.Add(benchEvent(fmt.Sprintf("session-%d", i/5), i))
+			if i == 0 {
+				first = id
+			}
+		}
+		if s.count != 10 {
+			t.Fatalf("store holds %d events, want 10", s.count)
+		}
+		if _, ok := s.Get(first); ok {
+			t.Error("oldest event kept past the store limit")
+		}
+		// Sessions 0 to 2 lost all their events.
+		if len(s.sessions) != 2 {
+			t.Errorf("store keeps %d sessions, want 2", len(s.sessions))
+		}
+		if got := len(s.GetBySession("session-4", 0)); got != 5 {
+			t.Errorf("newest session has %d events, want 5", got)
+		}
+	})
+
+	t.Run("wrapped", func(t *testing.T) {
+		s := NewEventStore()
+		s.SetLimit(4)
+		s.maxEvents = 6
+		// session-0 wraps, then loses its oldest to session-1's events and
+		// fills the freed slots again.
+		for i := 0; i < 6; i++ {
+			s.Add(benchEvent("session-0", i))
+		}
+		for i := 0; i < 4; i++ {
+			s.Add(benchEvent("session-1", i))
+		}
+		s.Add(benchEvent("session-0", 6))
+		events := s.GetBySession("session-0", 0)
+		var seqs []uint64
+		for _, e := range events {
+			seqs = append(seqs, e.Seq)
+		}
+		if want := []uint64{11, 6}; !slices.Equal(seqs, want) {
+			t.Errorf("session-0 holds events %v, want %v", seqs, want)
+		}
+	})
+
+	t.Run("payloads", func(t *testing.T) {
+		s := NewEventStore()
+		s.SetPayloadLimits(16, DefaultPayloadBytes)
+		s.maxPayloadSize = 1000
+		big := json.RawMessage(fmt.Sprintf(`{"command":%q}`, strings.Repeat("x", 400)))
+		for i := 0; i < 5; i++ {
+			s.Add(Event{SessionID: "session-0", EventName: "PreToolUse", ToolInput: big})
+		}
+		if s.payloadSize > s.maxPayloadSize {
+			t.Fatalf("payloads take %d bytes, over the limit of %d", s.payloadSize, s.maxPayloadSize)
+		}
+		if s.count != len(s.payloads) || s.count == 0 {
+			t.Errorf("store holds %d events and %d payloads", s.count, len(s.payloads))
+		}
+	})
+}
+
+// BenchmarkEventStore records and queries events from benchSessions
+// sessions in parallel, one goroutine per session or more.
+func BenchmarkEventStore(b *testing.B) {
+	b.Run("Add", func(b *testing.B) {
+		s := newBenchStore()
+		var next atomic.Int64
+		b.SetParallelism(benchSessions)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			id := fmt.Sprintf("session-%d", next.Add(1)%benchSessions)
+			for n := 0; pb.Next(); n++ {
+				s.Add(benchEvent(id, n))
+			}
+		})
+	})
+	b.Run("Query", func(b *testing.B) {
+		s := newBenchStore()
+		var next atomic.Int64
+		b.SetParallelism(benchSessions)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			id := fmt.Sprintf("session-%d", next.Add(1)%benchSessions)
+			for pb.Next() {
+				s.Query(EventQuery{SessionIDs: []string{id}, Limit: 50})
+			}
+		})
+	})
+	b.Run("AddQuery", func(b *testing.B) {
+		s := newBenchStore()
+		var next atomic.Int64
+		b.SetParallelism(benchSessions)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			id := fmt.Sprintf("session-%d", next.Add(1)%benchSessions)
+			for n := 0; pb.Next(); n++ {
+				// A tool call's two hooks, then a page of the feed.
+				s.Add(benchEvent(id, n))
+				if n%2 == 1 {
+					s.Query(EventQuery{SessionIDs: []string{id}, Limit: 50})
+				}
+			}
+		})
+	})
+}
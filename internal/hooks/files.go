@@ -0,0 +1,112 @@
+package hooks
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+// ToolFile returns the file a tool call reads or writes, resolved against
+// cwd, and whether the call modifies it. ok is false for tools that do not
+// operate on a single file.
+func ToolFile(toolName string, toolInput json.RawMessage, cwd string) (path string, write bool, ok bool) {
+	var input struct {
+		FilePath     string `json:"file_path"`
+		NotebookPath string `json:"notebook_path"`
+	}
+	if len(toolInput) == 0 || json.Unmarshal(toolInput, &input) != nil {
+		return "", false, false
+	}
+
+	switch toolName {
+	case "Read":
+		path = input.FilePath
+	case "Edit", "MultiEdit", "Write":
+		path, write = input.FilePath, true
+	case "NotebookEdit":
+		path, write = input.NotebookPath, true
+	default:
+		return "", false, false
+	}
+	if path == "" {
+		return "", false, false
+	}
+
+	if !filepath.IsAbs(path) && cwd != "" {
+		path = filepath.Join(cwd, path)
+	}
+	return filepath.Clean(path), write, true
+}
+
+// maxDiffBytes bounds the diff kept for one tool call.
+const maxDiffBytes = 8 << 10
+
+// ToolDiff renders the change a file-editing tool call makes as unified
+// "-"/"+" lines built from its input. It returns an empty string for tools
+// that do not edit files.
+func ToolDiff(toolName string, toolInput json.RawMessage) string {
+	var input struct {
+		OldString string `json:"old_string"`
+		NewString string `json:"new_string"`
+		Content   string `json:"content"`
+		NewSource string `json:"new_source"`
+		Edits     []struct {
+			OldString string `json:"old_string"`
+			NewString string `json:"new_string"`
+		} `json:"edits"`
+	}
+	if len(toolInput) == 0 || json.Unmarshal(toolInput, &input) != nil {
+		return ""
+	}
+
+	var b strings.Builder
+	switch toolName {
+	case "Edit":
+		writeDiffLines(&b, "-", input.OldString)
+		writeDiffLines(&b, "+", input.NewString)
+	case "MultiEdit":
+		for i, e := range input.Edits {
+			if i > 0 {
+				b.WriteString("@@\n")
+			}
+			writeDiffLines(&b, "-", e.OldString)
+			writeDiffLines(&b, "+", e.NewString)
+		}
+	case "Write":
+		writeDiffLines(&b, "+", input.Content)
+	case "NotebookEdit":
+		writeDiffLines(&b, "+", input.NewSource)
+	default:
+		return ""
+	}
+
+	diff := b.String()
+	if len(diff) > maxDiffBytes {
+		cut := strings.LastIndexByte(diff[:maxDiffBytes], '\n') + 1
+		diff = diff[:cut] + "… (truncated)\n"
+	}
+	return diff
+}
+
+func writeDiffLines(b *strings.Builder, prefix, text string) {
+	if text == "" {
+		return
+	}
+	for line := range strings.SplitSeq(strings.TrimSuffix(text, "\n"), "\n") {
+		b.WriteString(prefix)
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+}
+
+// ToolCreatedFile reports whether a Write tool response says the file was
+// newly created rather than overwritten.
+func ToolCreatedFile(toolName string, toolResponse json.RawMessage) bool {
+	if toolName != "Write" || len(toolResponse) == 0 {
+		return false
+	}
+	var resp struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(toolResponse, &resp) == nil && resp.Type == "create"
+}
@@ -0,0 +1,56 @@
+package hooks
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	digitRuns      = regexp.MustCompile(`[0-9]+`)
+	whitespaceRuns = regexp.MustCompile(`\s+`)
+)
+
+// ToolFingerprint identifies what a tool call does, so that repeated
+// attempts at the same call can be recognised. Calls that differ only in
+// whitespace, case or numbers (line numbers, timestamps, PIDs) share a
+// fingerprint. For file edits the fingerprint covers the file and the text
+// being replaced, so retrying a failing edit matches while different edits
+// to one file do not.
+func ToolFingerprint(toolName string, toolInput json.RawMessage) string {
+	var input struct {
+		Command      string `json:"command"`
+		FilePath     string `json:"file_path"`
+		NotebookPath string `json:"notebook_path"`
+		OldString    string `json:"old_string"`
+		Content      string `json:"content"`
+		NewSource    string `json:"new_source"`
+	}
+	var key string
+	if json.Unmarshal(toolInput, &input) == nil {
+		switch toolName {
+		case "Bash":
+			key = input.Command
+		case "Edit":
+			key = input.FilePath + "\x00" + input.OldString
+		case "Write":
+			key = input.FilePath + "\x00" + input.Content
+		case "NotebookEdit":
+			key = input.NotebookPath + "\x00" + input.NewSource
+		}
+	}
+	if key == "" {
+		// Other tools, and MultiEdit whose edits are all significant.
+		key = string(toolInput)
+	}
+
+	key = strings.ToLower(key)
+	key = digitRuns.ReplaceAllString(key, "#")
+	key = whitespaceRuns.ReplaceAllString(strings.TrimSpace(key), " ")
+
+	h := fnv.New64a()
+	h.Write([]byte(toolName + "\x00" + key))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
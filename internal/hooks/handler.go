@@ -5,26 +5,31 @@ import (
 )
 
 type HookInput struct {
-	SessionID      string          `json:"session_id"`
-	TranscriptPath string          `json:"transcript_path"`
-	Cwd            string          `json:"cwd"`
-	PermissionMode string          `json:"permission_mode"`
-	HookEventName  string          `json:"hook_event_name"`
-	ToolName       string          `json:"tool_name,omitempty"`
-	ToolInput      json.RawMessage `json:"tool_input,omitempty"`
-	ToolResponse   json.RawMessage `json:"tool_response,omitempty"`
-	ToolUseID      string          `json:"tool_use_id,omitempty"`
-	Message        string          `json:"message,omitempty"`
-	NotificationType string        `json:"notification_type,omitempty"`
-	Prompt         string          `json:"prompt,omitempty"`
-	StopHookActive bool            `json:"stop_hook_active,omitempty"`
-	Reason         string          `json:"reason,omitempty"`
-	Source         string          `json:"source,omitempty"`
+	SessionID          string          `json:"session_id"`
+	TranscriptPath     string          `json:"transcript_path"`
+	Cwd                string          `json:"cwd"`
+	PermissionMode     string          `json:"permission_mode"`
+	HookEventName      string          `json:"hook_event_name"`
+	ToolName           string          `json:"tool_name,omitempty"`
+	ToolInput          json.RawMessage `json:"tool_input,omitempty"`
+	ToolResponse       json.RawMessage `json:"tool_response,omitempty"`
+	ToolUseID          string          `json:"tool_use_id,omitempty"`
+	Message            string          `json:"message,omitempty"`
+	NotificationType   string          `json:"notification_type,omitempty"`
+	Prompt             string          `json:"prompt,omitempty"`
+	StopHookActive     bool            `json:"stop_hook_active,omitempty"`
+	Reason             string          `json:"reason,omitempty"`
+	Source             string          `json:"source,omitempty"`
+	Trigger            string          `json:"trigger,omitempty"`
+	CustomInstructions string          `json:"custom_instructions,omitempty"`
+	AgentID            string          `json:"agent_id,omitempty"`
+	AgentType          string          `json:"agent_type,omitempty"`
 }
 
 type ApprovalDecision struct {
-	Behavior string `json:"behavior"`
-	Message  string `json:"message,omitempty"`
+	Behavior     string          `json:"behavior"`
+	Message      string          `json:"message,omitempty"`
+	UpdatedInput json.RawMessage `json:"updatedInput,omitempty"`
 }
 
 type ApprovalResponse struct {
@@ -41,6 +46,14 @@ func NewAllowResponse() ApprovalResponse {
 	return resp
 }
 
+// NewAllowWithInputResponse allows the tool call with a replacement input,
+// e.g. an AskUserQuestion call carrying the user's answers.
+func NewAllowWithInputResponse(updatedInput json.RawMessage) ApprovalResponse {
+	resp := NewAllowResponse()
+	resp.HookSpecificOutput.Decision.UpdatedInput = updatedInput
+	return resp
+}
+
 func NewDenyResponse(message string) ApprovalResponse {
 	resp := ApprovalResponse{}
 	resp.HookSpecificOutput.HookEventName = "PermissionRequest"
@@ -49,12 +62,57 @@ func NewDenyResponse(message string) ApprovalResponse {
 	return resp
 }
 
-type HookEvent struct {
-	ID           string          `json:"id"`
-	SessionID    string          `json:"session_id"`
-	Timestamp    string          `json:"timestamp"`
-	EventName    string          `json:"event_name"`
-	ToolName     string          `json:"tool_name,omitempty"`
-	ToolInput    json.RawMessage `json:"tool_input,omitempty"`
-	ToolResponse json.RawMessage `json:"tool_response,omitempty"`
+// PreToolUseResponse carries a permission decision for a PreToolUse hook.
+// PermissionDecision is one of "allow", "deny" or "ask".
+type PreToolUseResponse struct {
+	HookSpecificOutput struct {
+		HookEventName            string `json:"hookEventName"`
+		PermissionDecision       string `json:"permissionDecision"`
+		PermissionDecisionReason string `json:"permissionDecisionReason,omitempty"`
+	} `json:"hookSpecificOutput"`
+}
+
+func NewPreToolUseResponse(decision, reason string) PreToolUseResponse {
+	resp := PreToolUseResponse{}
+	resp.HookSpecificOutput.HookEventName = "PreToolUse"
+	resp.HookSpecificOutput.PermissionDecision = decision
+	resp.HookSpecificOutput.PermissionDecisionReason = reason
+	return resp
+}
+
+// StopResponse asks Claude to keep working instead of stopping, using
+// Reason as its next instruction.
+type StopResponse struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+}
+
+func NewStopBlockResponse(reason string) StopResponse {
+	return StopResponse{Decision: "block", Reason: reason}
+}
+
+// PromptContextResponse adds context to a UserPromptSubmit hook.
+type PromptContextResponse struct {
+	HookSpecificOutput struct {
+		HookEventName     string `json:"hookEventName"`
+		AdditionalContext string `json:"additionalContext"`
+	} `json:"hookSpecificOutput"`
+}
+
+func NewPromptContextResponse(context string) PromptContextResponse {
+	resp := PromptContextResponse{}
+	resp.HookSpecificOutput.HookEventName = "UserPromptSubmit"
+	resp.HookSpecificOutput.AdditionalContext = context
+	return resp
+}
+
+// IsSubagentTool reports whether toolName launches a subagent.
+func IsSubagentTool(toolName string) bool {
+	return toolName == "Task" || toolName == "Agent"
+}
+
+// SubagentInput is the tool input of a subagent-launching tool call.
+type SubagentInput struct {
+	Description  string `json:"description"`
+	SubagentType string `json:"subagent_type"`
 }
@@ -0,0 +1,85 @@
+package hooks
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxPrompts = 2000
+
+// Prompt is a user prompt captured from a UserPromptSubmit hook.
+type Prompt struct {
+	ID         string    `json:"id"`
+	SessionID  string    `json:"session_id"`
+	ProjectDir string    `json:"project_dir"`
+	Timestamp  time.Time `json:"timestamp"`
+	Text       string    `json:"text"`
+	// Context is the pinned context injected alongside the prompt, if any.
+	Context string `json:"context,omitempty"`
+}
+
+// PromptQuery filters prompt history. Empty fields match everything; Text is
+// a case-insensitive substring match.
+type PromptQuery struct {
+	SessionID  string
+	ProjectDir string
+	Text       string
+	Limit      int
+}
+
+type PromptStore struct {
+	mu      sync.RWMutex
+	prompts []Prompt
+}
+
+func NewPromptStore() *PromptStore {
+	return &PromptStore{
+		prompts: make([]Prompt, 0, 100),
+	}
+}
+
+func (s *PromptStore) Add(sessionID, projectDir, text, context string) Prompt {
+	p := Prompt{
+		ID:         generateEventID(),
+		SessionID:  sessionID,
+		ProjectDir: projectDir,
+		Timestamp:  time.Now(),
+		Text:       text,
+		Context:    context,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prompts = append(s.prompts, p)
+	if len(s.prompts) > maxPrompts {
+		s.prompts = append(s.prompts[:0:0], s.prompts[len(s.prompts)-maxPrompts:]...)
+	}
+	return p
+}
+
+// Search returns matching prompts, newest first.
+func (s *PromptStore) Search(q PromptQuery) []Prompt {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	text := strings.ToLower(q.Text)
+	result := make([]Prompt, 0)
+	for i := len(s.prompts) - 1; i >= 0; i-- {
+		p := s.prompts[i]
+		if q.SessionID != "" && p.SessionID != q.SessionID {
+			continue
+		}
+		if q.ProjectDir != "" && p.ProjectDir != q.ProjectDir {
+			continue
+		}
+		if text != "" && !strings.Contains(strings.ToLower(p.Text), text) {
+			continue
+		}
+		result = append(result, p)
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// QuestionToolName is the tool Claude uses to ask the user multiple-choice
+// or free-text questions.
+const QuestionToolName = "AskUserQuestion"
+
+type Question struct {
+	Question    string           `json:"question"`
+	Header      string           `json:"header,omitempty"`
+	Options     []QuestionOption `json:"options,omitempty"`
+	MultiSelect bool             `json:"multiSelect,omitempty"`
+}
+
+type QuestionOption struct {
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+}
+
+// ParseQuestions extracts the questions from a question tool call. It returns
+// nil for any other tool or for input it cannot parse.
+func ParseQuestions(toolName string, toolInput json.RawMessage) []Question {
+	if toolName != QuestionToolName || len(toolInput) == 0 {
+		return nil
+	}
+	var input struct {
+		Questions []Question `json:"questions"`
+	}
+	if err := json.Unmarshal(toolInput, &input); err != nil {
+		return nil
+	}
+	return input.Questions
+}
+
+// AnswerQuestions returns toolInput with answers (keyed by question text)
+// added, ready to hand back to Claude as the tool's updated input.
+func AnswerQuestions(toolInput json.RawMessage, answers map[string]string) (json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(toolInput, &fields); err != nil {
+		return nil, fmt.Errorf("parsing tool input: %w", err)
+	}
+	encoded, err := json.Marshal(answers)
+	if err != nil {
+		return nil, fmt.Errorf("encoding answers: %w", err)
+	}
+	fields["answers"] = encoded
+	return json.Marshal(fields)
+}
@@ -0,0 +1,119 @@
+package hooks
+
+import "sort"
+
+// sessionEvents is a ring buffer of one session's latest events, oldest
+// first by Seq.
+type sessionEvents struct {
+	// buf grows to limit and then wraps, overwriting the oldest event. It
+	// holds n events from head on; the store may take the oldest out to
+	// stay under its own limits.
+	buf  []Event
+	head int
+	n    int
+	// limit is the session's own cap, or zero to follow the store's.
+	limit int
+	// pending maps the tool_use_id of each PreToolUse still in the buffer
+	// to its Seq, to time the PostToolUse that completes it.
+	pending map[string]uint64
+}
+
+func newSessionEvents() *sessionEvents {
+	return &sessionEvents{pending: make(map[string]uint64)}
+}
+
+func (r *sessionEvents) len() int {
+	return r.n
+}
+
+// at returns the i-th oldest event.
+func (r *sessionEvents) at(i int) *Event {
+	return &r.buf[(r.head+i)%len(r.buf)]
+}
+
+// push appends an event, evicting and returning the oldest if the buffer
+// holds capacity events already.
+func (r *sessionEvents) push(event Event, capacity int) (Event, bool) {
+	if event.EventName == "PreToolUse" && event.ToolUseID != "" {
+		r.pending[event.ToolUseID] = event.Seq
+	}
+	switch {
+	case r.n < len(r.buf):
+		r.buf[(r.head+r.n)%len(r.buf)] = event
+		r.n++
+		return Event{}, false
+	case r.n < capacity:
+		r.linearize()
+		r.buf = append(r.buf, event)
+		r.n++
+		return Event{}, false
+	}
+	evicted := r.buf[r.head]
+	r.buf[r.head] = event
+	r.head = (r.head + 1) % len(r.buf)
+	r.forget(evicted)
+	return evicted, true
+}
+
+// shift removes and returns the oldest event.
+func (r *sessionEvents) shift() Event {
+	evicted := r.buf[r.head]
+	r.buf[r.head] = Event{}
+	r.head = (r.head + 1) % len(r.buf)
+	r.n--
+	r.forget(evicted)
+	return evicted
+}
+
+// linearize moves the events to the start of buf, oldest first.
+func (r *sessionEvents) linearize() {
+	if r.head == 0 && r.n == len(r.buf) {
+		return
+	}
+	events := make([]Event, r.n, max(r.n, len(r.buf)))
+	for i := range events {
+		events[i] = *r.at(i)
+	}
+	r.buf, r.head = events, 0
+}
+
+// resize keeps the newest capacity events and returns those dropped.
+func (r *sessionEvents) resize(capacity int) []Event {
+	n := r.len()
+	drop := max(n-capacity, 0)
+	evicted := make([]Event, 0, drop)
+	kept := make([]Event, 0, n-drop)
+	for i := 0; i < n; i++ {
+		if i < drop {
+			evicted = append(evicted, *r.at(i))
+			r.forget(*r.at(i))
+		} else {
+			kept = append(kept, *r.at(i))
+		}
+	}
+	r.buf, r.head, r.n = kept, 0, len(kept)
+	return evicted
+}
+
+func (r *sessionEvents) forget(event Event) {
+	if seq, ok := r.pending[event.ToolUseID]; ok && seq == event.Seq {
+		delete(r.pending, event.ToolUseID)
+	}
+}
+
+// find returns the position of the event with the given Seq.
+func (r *sessionEvents) find(seq uint64) (int, bool) {
+	n := r.len()
+	i := sort.Search(n, func(i int) bool { return r.at(i).Seq >= seq })
+	return i, i < n && r.at(i).Seq == seq
+}
+
+// before returns the number of events older than seq, so the newest of
+// them is at(before-1). Zero seq counts every event.
+func (r *sessionEvents) before(seq uint64) int {
+	n := r.len()
+	if seq == 0 {
+		return n
+	}
+	return sort.Search(n, func(i int) bool { return r.at(i).Seq >= seq })
+}
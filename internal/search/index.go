@@ -0,0 +1,367 @@
+// Package search keeps an in-memory full-text index of what sessions did:
+// tool calls, prompts, notifications and transcript text.
+package search
+
+import (
+	"encoding/json"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kinds of indexed documents.
+const (
+	KindTool         = "tool"
+	KindPrompt       = "prompt"
+	KindNotification = "notification"
+	KindTranscript   = "transcript"
+)
+
+const (
+	// DefaultMaxDocs bounds the documents kept; the oldest are dropped
+	// first.
+	DefaultMaxDocs = 20000
+	// MaxTextBytes is how much of a document's text is indexed.
+	MaxTextBytes = 4 << 10
+	// snippetBytes is the length of the excerpt shown for a hit.
+	snippetBytes = 160
+	// maxTermBytes skips tokens such as base64 blobs that nobody searches
+	// for.
+	maxTermBytes = 64
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// prefixWeight scales matches of a term as a prefix of a longer one.
+	prefixWeight = 0.5
+)
+
+// Doc is one indexed item.
+type Doc struct {
+	// Key identifies the document; adding a document with the same key
+	// replaces it.
+	Key       string    `json:"-"`
+	Kind      string    `json:"kind"`
+	SessionID string    `json:"session_id"`
+	Project   string    `json:"project"`
+	EventID   string    `json:"event_id,omitempty"`
+	Tool      string    `json:"tool,omitempty"`
+	Time      time.Time `json:"time"`
+	Text      string    `json:"-"`
+}
+
+// Hit is a document matching a query.
+type Hit struct {
+	Doc
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// Query selects documents. Every term of Text must match, the last as a
+// prefix so results can follow typing. Empty fields other than Text match
+// everything.
+type Query struct {
+	Text      string
+	SessionID string
+	Project   string
+	Kinds     []string
+	Limit     int
+}
+
+type entry struct {
+	doc Doc
+	// terms are the document's distinct terms with their frequencies.
+	terms  map[string]int
+	length int
+}
+
+// Index is an inverted index over documents.
+type Index struct {
+	mu       sync.RWMutex
+	maxDocs  int
+	nextID   uint64
+	docs     map[uint64]*entry
+	keys     map[string]uint64
+	postings map[string]map[uint64]int
+	// order lists document IDs oldest first for eviction; IDs of replaced
+	// documents are skipped.
+	order       []uint64
+	totalLength int
+
+	transcriptMu sync.Mutex
+	transcripts  map[string]int64
+}
+
+func NewIndex(maxDocs int) *Index {
+	if maxDocs <= 0 {
+		maxDocs = DefaultMaxDocs
+	}
+	return &Index{
+		maxDocs:     maxDocs,
+		docs:        make(map[uint64]*entry),
+		keys:        make(map[string]uint64),
+		postings:    make(map[string]map[uint64]int),
+		transcripts: make(map[string]int64),
+	}
+}
+
+// Terms splits text into lowercase search terms: runs of letters, digits
+// and underscores. Punctuation separates terms, so auth.go is auth and go.
+func Terms(text string) []string {
+	var terms []string
+	for _, field := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		if len(field) <= maxTermBytes {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+// FlattenJSON returns the string values of a JSON document, one per line,
+// so tool inputs are indexed by their content rather than their field
+// names. Text that is not a JSON object or array is returned unchanged.
+func FlattenJSON(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return text
+	}
+	var doc any
+	if json.Unmarshal([]byte(trimmed), &doc) != nil {
+		return text
+	}
+	var values []string
+	var walk func(any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				values = append(values, v)
+			}
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(v[k])
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(doc)
+	return strings.Join(values, "\n")
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// Add indexes a document, replacing any with the same key.
+func (idx *Index) Add(doc Doc) {
+	doc.Text = truncate(doc.Text, MaxTextBytes)
+	e := &entry{doc: doc, terms: make(map[string]int)}
+	for _, term := range Terms(doc.Text) {
+		e.terms[term]++
+		e.length++
+	}
+	if e.length == 0 {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if id, ok := idx.keys[doc.Key]; ok {
+		idx.remove(id)
+	}
+	idx.nextID++
+	id := idx.nextID
+	idx.docs[id] = e
+	idx.keys[doc.Key] = id
+	idx.order = append(idx.order, id)
+	idx.totalLength += e.length
+	for term, tf := range e.terms {
+		docs, ok := idx.postings[term]
+		if !ok {
+			docs = make(map[uint64]int)
+			idx.postings[term] = docs
+		}
+		docs[id] = tf
+	}
+
+	for len(idx.docs) > idx.maxDocs {
+		oldest := idx.order[0]
+		idx.order = idx.order[1:]
+		idx.remove(oldest)
+	}
+	if len(idx.order) > 2*idx.maxDocs {
+		// Drop the IDs of replaced documents.
+		live := idx.order[:0]
+		for _, id := range idx.order {
+			if _, ok := idx.docs[id]; ok {
+				live = append(live, id)
+			}
+		}
+		idx.order = live
+	}
+}
+
+// Has reports whether a document with the key is indexed.
+func (idx *Index) Has(key string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	_, ok := idx.keys[key]
+	return ok
+}
+
+// remove drops a document. Callers hold idx.mu.
+func (idx *Index) remove(id uint64) {
+	e, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for term := range e.terms {
+		docs := idx.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	if idx.keys[e.doc.Key] == id {
+		delete(idx.keys, e.doc.Key)
+	}
+	idx.totalLength -= e.length
+	delete(idx.docs, id)
+}
+
+// Search returns the documents matching q, best first, ranked by BM25 with
+// a boost for documents containing the query as typed. Ties go to the most
+// recent.
+func (idx *Index) Search(q Query) []Hit {
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		return []Hit{}
+	}
+	phrase := strings.ToLower(strings.TrimSpace(q.Text))
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.docs))
+	avgLength := float64(idx.totalLength) / max(n, 1)
+	var scores map[uint64]float64
+	for i, term := range terms {
+		// The last term also matches the indexed terms it prefixes, at a
+		// discount; a document scores for its best match.
+		termScores := make(map[uint64]float64)
+		score := func(docs map[uint64]int, weight float64) {
+			df := float64(len(docs))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for id, tf := range docs {
+				length := float64(idx.docs[id].length)
+				s := weight * idf * float64(tf) * (bm25K1 + 1) /
+					(float64(tf) + bm25K1*(1-bm25B+bm25B*length/avgLength))
+				termScores[id] = max(termScores[id], s)
+			}
+		}
+		if i == len(terms)-1 {
+			for t, docs := range idx.postings {
+				if t != term && strings.HasPrefix(t, term) {
+					score(docs, prefixWeight)
+				}
+			}
+		}
+		score(idx.postings[term], 1)
+
+		// Every term must match.
+		if i == 0 {
+			scores = termScores
+		} else {
+			for id := range scores {
+				if s, ok := termScores[id]; ok {
+					scores[id] += s
+				} else {
+					delete(scores, id)
+				}
+			}
+		}
+		if len(scores) == 0 {
+			return []Hit{}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		e := idx.docs[id]
+		if q.SessionID != "" && e.doc.SessionID != q.SessionID {
+			continue
+		}
+		if q.Project != "" && e.doc.Project != q.Project {
+			continue
+		}
+		if len(q.Kinds) > 0 && !slices.Contains(q.Kinds, e.doc.Kind) {
+			continue
+		}
+		lower := strings.ToLower(e.doc.Text)
+		if strings.Contains(lower, phrase) {
+			score *= 1.5
+		}
+		hits = append(hits, Hit{Doc: e.doc, Score: math.Round(score*1000) / 1000, Snippet: snippet(e.doc.Text, lower, phrase, terms)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Time.After(hits[j].Time)
+	})
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits
+}
+
+// snippet returns an excerpt of text around the first match of the phrase
+// or, failing that, of any term, with whitespace collapsed.
+func snippet(text, lower, phrase string, terms []string) string {
+	at := -1
+	if len(lower) == len(text) {
+		at = strings.Index(lower, phrase)
+		for _, term := range terms {
+			if at >= 0 {
+				break
+			}
+			at = strings.Index(lower, term)
+		}
+	}
+	start := max(at-snippetBytes/3, 0)
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	excerpt := truncate(text[start:], snippetBytes)
+	excerpt = strings.Join(strings.Fields(excerpt), " ")
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if start+snippetBytes < len(text) {
+		excerpt += "…"
+	}
+	return excerpt
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/transcript"
+)
+
+// transcriptLine is the part of a transcript line carrying assistant text.
+type transcriptLine struct {
+	Type      string    `json:"type"`
+	UUID      string    `json:"uuid"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   struct {
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+type contentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// UpdateTranscript indexes the assistant text appended to a session's
+// transcript since the last call. clean is applied to the text before it
+// is indexed, to mask secrets. Lines already indexed, such as those a
+// resumed session replays, stay with the session that first reported them.
+func (idx *Index) UpdateTranscript(sessionID, project, path string, clean func(string) string) error {
+	idx.transcriptMu.Lock()
+	defer idx.transcriptMu.Unlock()
+
+	offset, err := transcript.ReadFrom(path, idx.transcripts[path], func(line []byte) {
+		if !bytes.Contains(line, []byte(`"text"`)) {
+			return
+		}
+		var entry transcriptLine
+		if json.Unmarshal(line, &entry) != nil || entry.Type != "assistant" || entry.UUID == "" {
+			return
+		}
+		var blocks []contentBlock
+		if json.Unmarshal(entry.Message.Content, &blocks) != nil {
+			return
+		}
+		var text []string
+		for _, b := range blocks {
+			if b.Type == "text" && b.Text != "" {
+				text = append(text, b.Text)
+			}
+		}
+		key := KindTranscript + ":" + entry.UUID
+		if len(text) == 0 || idx.Has(key) {
+			return
+		}
+		idx.Add(Doc{
+			Key:       key,
+			Kind:      KindTranscript,
+			SessionID: sessionID,
+			Project:   project,
+			Time:      entry.Timestamp,
+			Text:      clean(strings.Join(text, "\n")),
+		})
+	})
+	idx.transcripts[path] = offset
+	return err
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/session"
+)
+
+// Alert types, also used as webhook event names.
+const (
+	AlertBypassPermissions = "bypass_permissions"
+	AlertFileConflict      = "file_conflict"
+	AlertBudget            = "budget"
+	AlertCircuitBreaker    = "circuit_breaker"
+)
+
+// Alert is a notable session event surfaced in the web UI and sent to the
+// configured webhooks.
+type Alert struct {
+	Type       string         `json:"type"`
+	Message    string         `json:"message"`
+	SessionID  string         `json:"session_id"`
+	Nickname   string         `json:"nickname"`
+	Project    string         `json:"project"`
+	ProjectDir string         `json:"project_dir"`
+	Timestamp  time.Time      `json:"timestamp"`
+	Details    map[string]any `json:"details,omitempty"`
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func newAlert(alertType string, sess *session.Session, message string) Alert {
+	return Alert{
+		Type:       alertType,
+		Message:    message,
+		SessionID:  sess.ID,
+		Nickname:   sess.Nickname,
+		Project:    sess.Project,
+		ProjectDir: sess.ProjectDir,
+		Timestamp:  time.Now().UTC(),
+	}
+}
+
+// raiseAlert shows the alert in every connected browser and posts it to the
+// webhooks subscribed to its type.
+func (s *Server) raiseAlert(alert Alert) {
+	slog.Warn("alert raised",
+		"type", alert.Type,
+		"session_id", alert.SessionID,
+		"message", alert.Message)
+
+	s.hub.Broadcast(Message{
+		Type:      "alert",
+		SessionID: alert.SessionID,
+		Data: map[string]any{
+			"type":    alert.Type,
+			"message": alert.Message,
+		},
+	})
+
+	for _, hook := range s.cfg.CurrentSettings().Webhooks {
+		if hook.Wants(alert.Type) {
+			go postWebhook(hook.URL, alert)
+		}
+	}
+}
+
+func postWebhook(url string, alert Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		slog.Error("failed to encode webhook payload", "error", err)
+		return
+	}
+
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Warn("webhook delivery failed", "url", url, "type", alert.Type, "error", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		slog.Warn("webhook rejected alert", "url", url, "type", alert.Type, "status", resp.StatusCode)
+	}
+}
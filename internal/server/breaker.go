@@ -0,0 +1,64 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/aliadnani/claudehaus/internal/config"
+	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/session"
+)
+
+func (s *Server) breakerLimits() session.BreakerLimits {
+	b := s.cfg.CurrentSettings().Breaker
+	return session.BreakerLimits{
+		Repeats:   settingInt(b.RepeatThreshold, config.DefaultBreakerRepeatThreshold),
+		Window:    settingSeconds(b.RepeatWindowSeconds, config.DefaultBreakerRepeatWindowSeconds),
+		PerMinute: settingInt(b.MaxCallsPerMinute, config.DefaultBreakerMaxCallsPerMinute),
+	}
+}
+
+// checkBreaker records a tool call with the session's circuit breaker and
+// raises an alert if the call tripped it.
+func (s *Server) checkBreaker(sess *session.Session, input hooks.HookInput) {
+	if input.ToolName == "" {
+		return
+	}
+	fingerprint := hooks.ToolFingerprint(input.ToolName, input.ToolInput)
+	trip, tripped := s.breaker.Record(sess.ID, input.ToolUseID, input.ToolName, fingerprint, s.breakerLimits())
+	if !tripped {
+		return
+	}
+
+	s.events.Add(hooks.Event{
+		SessionID: sess.ID,
+		EventName: "CircuitBreaker",
+		ToolName:  input.ToolName,
+		ToolUseID: input.ToolUseID,
+		ToolInput: input.ToolInput,
+		Detail:    "Tripped: " + trip.Reason,
+	})
+	s.hub.Broadcast(Message{Type: "session_update", SessionID: sess.ID, Data: map[string]any{"breaker": "tripped"}})
+
+	alert := newAlert(AlertCircuitBreaker, sess, sess.Nickname+" may be stuck: "+trip.Reason)
+	alert.Details = map[string]any{
+		"reason": trip.Reason,
+		"tool":   trip.Tool,
+	}
+	s.raiseAlert(alert)
+}
+
+func (s *Server) handleResetBreaker(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.sessions.Get(id); !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	if s.breaker.Reset(id) {
+		s.events.AddEvent(id, "CircuitBreaker", "Reset")
+		s.hub.Broadcast(Message{Type: "session_update", SessionID: id, Data: map[string]any{"breaker": "reset"}})
+		slog.Info("circuit breaker reset", "session_id", id)
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/config"
+	"github.com/aliadnani/claudehaus/internal/session"
+)
+
+// budgetStep is how far spending has gone against a budget, from least to
+// most severe.
+type budgetStep int
+
+const (
+	budgetOK budgetStep = iota
+	budgetWarn
+	budgetReview
+	budgetDeny
+)
+
+func (b budgetStep) String() string {
+	switch b {
+	case budgetWarn:
+		return "warn"
+	case budgetReview:
+		return "review"
+	case budgetDeny:
+		return "deny"
+	default:
+		return "ok"
+	}
+}
+
+func (b budgetStep) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// budgetStatus is spending against one budget that applies to a session.
+type budgetStatus struct {
+	// Scope is "session", "project" or "daily".
+	Scope   string     `json:"scope"`
+	Spent   float64    `json:"spent_usd"`
+	Limit   float64    `json:"limit_usd"`
+	Percent int        `json:"percent"`
+	Step    budgetStep `json:"step"`
+	// key identifies the budget across sessions, so a shared budget is only
+	// escalated once.
+	key string
+}
+
+func (b budgetStatus) String() string {
+	return fmt.Sprintf("%s budget %d%% used (%s of %s)",
+		b.Scope, b.Percent, formatCost(b.Spent), formatCost(b.Limit))
+}
+
+// budgetTracker remembers the step each budget last reached, so alerts are
+// raised once per step rather than on every usage update.
+type budgetTracker struct {
+	mu    sync.Mutex
+	steps map[string]budgetStep
+}
+
+func newBudgetTracker() *budgetTracker {
+	return &budgetTracker{steps: make(map[string]budgetStep)}
+}
+
+// advance records the step a budget is at and reports whether it is more
+// severe than before. Raising a budget lowers its step, so crossing it
+// again alerts again.
+func (t *budgetTracker) advance(key string, step budgetStep) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prev := t.steps[key]
+	t.steps[key] = step
+	return step > prev
+}
+
+// forget drops the step of an ended session's own budget.
+func (t *budgetTracker) forget(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.steps, "session:"+sessionID)
+}
+
+func budgetStepAt(percent int, b config.Budgets) budgetStep {
+	reached := func(value, def int) bool {
+		threshold := settingInt(value, def)
+		return threshold >= 0 && percent >= threshold
+	}
+	switch {
+	case reached(b.DenyPercent, config.DefaultBudgetDenyPercent):
+		return budgetDeny
+	case reached(b.ReviewPercent, config.DefaultBudgetReviewPercent):
+		return budgetReview
+	case reached(b.WarnPercent, config.DefaultBudgetWarnPercent):
+		return budgetWarn
+	default:
+		return budgetOK
+	}
+}
+
+// checkBudgetPercents validates warn, review and deny percentages as they
+// would be after an update: the ones given must be positive, and the steps
+// must rise from warn to review to deny. Steps disabled in the config file
+// are left out of the order.
+func checkBudgetPercents(current config.Budgets, warn, review, deny *int) error {
+	if warn == nil && review == nil && deny == nil {
+		return nil
+	}
+	steps := []struct {
+		name  string
+		value *int
+		def   int
+		prev  int
+	}{
+		{"warn_percent", warn, config.DefaultBudgetWarnPercent, current.WarnPercent},
+		{"review_percent", review, config.DefaultBudgetReviewPercent, current.ReviewPercent},
+		{"deny_percent", deny, config.DefaultBudgetDenyPercent, current.DenyPercent},
+	}
+	last, lastName := 0, ""
+	for _, step := range steps {
+		percent := settingInt(step.prev, step.def)
+		if step.value != nil {
+			if *step.value <= 0 {
+				return fmt.Errorf("%s must be positive", step.name)
+			}
+			percent = *step.value
+		}
+		if percent < 0 {
+			continue
+		}
+		if percent <= last {
+			return fmt.Errorf("%s must be greater than %s", step.name, lastName)
+		}
+		last, lastName = percent, step.name
+	}
+	return nil
+}
+
+// budgets returns spending against each budget that applies to a session.
+func (s *Server) budgets(sess *session.Session) []budgetStatus {
+	settings := s.cfg.CurrentSettings().Budgets
+	prices := s.priceTable()
+	today := time.Now().Format(time.DateOnly)
+
+	candidates := []budgetStatus{
+		{Scope: "session", key: "session:" + sess.ID, Limit: settings.SessionUSD},
+		{Scope: "project", key: "project:" + sess.Project, Limit: s.cfg.ProjectBudget(sess.Project)},
+		{Scope: "daily", key: "daily:" + today, Limit: settings.DailyUSD},
+	}
+	result := make([]budgetStatus, 0, len(candidates))
+	for _, b := range candidates {
+		if b.Limit <= 0 {
+			continue
+		}
+		switch b.Scope {
+		case "session":
+			b.Spent = prices.Cost(s.usage.Session(sess.ID))
+		case "project":
+			b.Spent = prices.Cost(s.usage.Project(sess.Project))
+		case "daily":
+			b.Spent = prices.Cost(s.usage.Date(today))
+		}
+		b.Percent = int(b.Spent / b.Limit * 100)
+		b.Step = budgetStepAt(b.Percent, settings)
+		result = append(result, b)
+	}
+	return result
+}
+
+// worstBudget returns the budget furthest along for a session.
+func (s *Server) worstBudget(sess *session.Session) (budgetStatus, bool) {
+	var worst budgetStatus
+	found := false
+	for _, b := range s.budgets(sess) {
+		if !found || b.Step > worst.Step || (b.Step == worst.Step && b.Percent > worst.Percent) {
+			worst, found = b, true
+		}
+	}
+	return worst, found
+}
+
+// checkBudgets raises an alert for each budget that reached a more severe
+// step since the last check.
+func (s *Server) checkBudgets(sess *session.Session) {
+	for _, b := range s.budgets(sess) {
+		if !s.budgetSteps.advance(b.key, b.Step) {
+			continue
+		}
+		var consequence string
+		switch b.Step {
+		case budgetWarn:
+			consequence = "approaching its limit"
+		case budgetReview:
+			consequence = "tool calls now need approval"
+		case budgetDeny:
+			consequence = "tool calls are now denied"
+		}
+		message := b.String() + ": " + consequence
+		s.events.AddEvent(sess.ID, "Budget", message)
+
+		alert := newAlert(AlertBudget, sess, sess.Nickname+": "+message)
+		alert.Details = map[string]any{
+			"scope":     b.Scope,
+			"spent_usd": b.Spent,
+			"limit_usd": b.Limit,
+			"step":      b.Step.String(),
+		}
+		s.raiseAlert(alert)
+	}
+}
+
+// budgetDenial is the reason given to Claude for a tool call denied by a
+// budget.
+func budgetDenial(b budgetStatus) string {
+	return "Denied by Claudehaus: the " + b.String() + ". Stop and tell the user; they can raise the budget in Claudehaus to continue."
+}
+
+func (s *Server) handleListBudgets(w http.ResponseWriter, r *http.Request) {
+	result := map[string]any{"settings": s.cfg.CurrentSettings().Budgets}
+	if id := r.URL.Query().Get("session"); id != "" {
+		sess, ok := s.sessions.Get(id)
+		if !ok {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+		result["budgets"] = s.budgets(sess)
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) handleUpdateBudgets(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SessionUSD    *float64 `json:"session_usd,omitempty"`
+		ProjectUSD    *float64 `json:"project_usd,omitempty"`
+		DailyUSD      *float64 `json:"daily_usd,omitempty"`
+		WarnPercent   *int     `json:"warn_percent,omitempty"`
+		ReviewPercent *int     `json:"review_percent,omitempty"`
+		DenyPercent   *int     `json:"deny_percent,omitempty"`
+		// ProjectDir sets ProjectUSD for one project instead of the default.
+		ProjectDir string `json:"project_dir,omitempty"`
+	}
+	if r.Header.Get("HX-Request") == "" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+	} else {
+		// The budget form in the session view edits the limits; an empty
+		// field removes a budget.
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		for name, field := range map[string]**float64{
+			"session_usd": &req.SessionUSD,
+			"project_usd": &req.ProjectUSD,
+			"daily_usd":   &req.DailyUSD,
+		} {
+			if !r.Form.Has(name) {
+				continue
+			}
+			value := 0.0
+			if v := strings.TrimSpace(strings.TrimPrefix(r.FormValue(name), "$")); v != "" {
+				var err error
+				if value, err = strconv.ParseFloat(v, 64); err != nil {
+					http.Error(w, "invalid "+name, http.StatusBadRequest)
+					return
+				}
+			}
+			*field = &value
+		}
+		req.ProjectDir = r.FormValue("project_dir")
+	}
+	for _, v := range []*float64{req.SessionUSD, req.ProjectUSD, req.DailyUSD} {
+		if v != nil && *v < 0 {
+			http.Error(w, "budgets must not be negative", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := checkBudgetPercents(s.cfg.CurrentSettings().Budgets, req.WarnPercent, req.ReviewPercent, req.DenyPercent); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var budgets config.Budgets
+	err := s.cfg.UpdateSettings(func(settings *config.Settings) {
+		b := &settings.Budgets
+		if req.SessionUSD != nil {
+			b.SessionUSD = *req.SessionUSD
+		}
+		if req.DailyUSD != nil {
+			b.DailyUSD = *req.DailyUSD
+		}
+		if req.WarnPercent != nil {
+			b.WarnPercent = *req.WarnPercent
+		}
+		if req.ReviewPercent != nil {
+			b.ReviewPercent = *req.ReviewPercent
+		}
+		if req.DenyPercent != nil {
+			b.DenyPercent = *req.DenyPercent
+		}
+		if req.ProjectUSD != nil && req.ProjectDir == "" {
+			b.ProjectUSD = *req.ProjectUSD
+		}
+		budgets = *b
+	})
+	if err == nil && req.ProjectUSD != nil && req.ProjectDir != "" {
+		_, err = s.cfg.UpdateProject(req.ProjectDir, func(p *config.ProjectMeta) {
+			p.BudgetUSD = *req.ProjectUSD
+		})
+	}
+	if err != nil {
+		http.Error(w, "failed to save budgets", http.StatusInternalServerError)
+		return
+	}
+
+	// Re-evaluate every session so a raised budget lets sessions continue
+	// and a lowered one alerts now rather than at the next response.
+	for _, sess := range s.sessions.All() {
+		s.checkBudgets(sess)
+	}
+	s.hub.Broadcast(Message{Type: "usage_update"})
+
+	slog.Info("budgets updated",
+		"session_usd", budgets.SessionUSD,
+		"project_usd", budgets.ProjectUSD,
+		"daily_usd", budgets.DailyUSD,
+		"project_dir", req.ProjectDir)
+	writeJSON(w, map[string]any{"settings": budgets})
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/config"
+	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/session"
+)
+
+// recordFileAccess adds the file a tool call reads or modifies to the
+// index. completed is set for PostToolUse, once the call has run.
+func (s *Server) recordFileAccess(input hooks.HookInput, eventID string, completed bool) {
+	path, write, ok := hooks.ToolFile(input.ToolName, input.ToolInput, input.Cwd)
+	if !ok {
+		return
+	}
+	s.files.Record(input.SessionID, path, session.FileAccess{
+		ToolUseID: input.ToolUseID,
+		EventIDs:  []string{eventID},
+		Tool:      input.ToolName,
+		Write:     write,
+		Completed: completed,
+		Diff:      hooks.ToolDiff(input.ToolName, input.ToolInput),
+	}, completed && hooks.ToolCreatedFile(input.ToolName, input.ToolResponse))
+	s.queueFiles(input.SessionID)
+}
+
+// fileConflict describes other active sessions that recently modified the
+// file a tool call is about to modify. The warning is empty when there is
+// no conflict.
+func (s *Server) fileConflict(input hooks.HookInput) (path string, warning string, others []*session.Session) {
+	window := settingSeconds(s.cfg.CurrentSettings().ConflictWindowSeconds, config.DefaultConflictWindowSeconds)
+	if window <= 0 {
+		return "", "", nil
+	}
+	path, write, ok := hooks.ToolFile(input.ToolName, input.ToolInput, input.Cwd)
+	if !ok || !write {
+		return "", "", nil
+	}
+
+	names := make([]string, 0)
+	for _, writer := range s.files.ConflictingWriters(input.SessionID, path, time.Now().Add(-window)) {
+		other, ok := s.sessions.Get(writer.SessionID)
+		if !ok || other.Status == session.StatusEnded {
+			continue
+		}
+		others = append(others, other)
+		// Sessions in a project often share a nickname, so include the ID.
+		names = append(names, fmt.Sprintf("%s (%s) at %s", other.Nickname, other.ID[:min(8, len(other.ID))], writer.At.Format("15:04:05")))
+	}
+	if len(others) == 0 {
+		return "", "", nil
+	}
+	return path, path + " was modified by " + strings.Join(names, ", "), others
+}
+
+// warnFileConflict records a conflict in the session feed and raises an
+// alert for it.
+func (s *Server) warnFileConflict(sess *session.Session, path, warning string, others []*session.Session) {
+	s.events.AddEvent(sess.ID, "FileConflict", warning)
+
+	ids := make([]string, 0, len(others))
+	for _, other := range others {
+		ids = append(ids, other.ID)
+	}
+	alert := newAlert(AlertFileConflict, sess, sess.Nickname+" is editing "+path+", recently modified by another session")
+	alert.Details = map[string]any{
+		"path":           path,
+		"other_sessions": ids,
+	}
+	s.raiseAlert(alert)
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/session"
+)
+
+// Page sizes for event queries.
+const (
+	defaultEventPage = 100
+	maxEventPage     = 1000
+)
+
+var errInvalidLimit = errors.New("invalid limit")
+
+// applyEventLimits sets how many events each session keeps, from the
+// settings and any project overrides.
+func (s *Server) applyEventLimits() {
+	s.events.SetLimit(s.cfg.CurrentSettings().EventsPerSession)
+	for _, sess := range s.sessions.All() {
+		s.applySessionEventLimit(sess)
+	}
+}
+
+// applySessionEventLimit applies the event limit of a session's project.
+func (s *Server) applySessionEventLimit(sess *session.Session) {
+	s.events.SetSessionLimit(sess.ID, s.cfg.Project(sess.Project).EventsPerSession)
+}
+
+// listParam returns the values of a query parameter given as a
+// comma-separated list, repeated, or both.
+func listParam(q url.Values, name string) []string {
+	var values []string
+	for _, v := range q[name] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// parseEventTime reads an RFC 3339 time, or a duration such as 15m meaning
+// that long ago.
+func parseEventTime(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseEventQuery reads the event filters shared by the events API and the
+// session feed: event, tool, since, until, q, cursor and limit.
+func parseEventQuery(q url.Values, defaultLimit int) (hooks.EventQuery, error) {
+	query := hooks.EventQuery{
+		EventNames: listParam(q, "event"),
+		ToolNames:  listParam(q, "tool"),
+		Text:       strings.TrimSpace(q.Get("q")),
+		Limit:      defaultLimit,
+	}
+	for name, field := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if v := q.Get(name); v != "" {
+			t, err := parseEventTime(v)
+			if err != nil {
+				return query, errors.New("invalid " + name)
+			}
+			*field = t
+		}
+	}
+	if cursor := q.Get("cursor"); cursor != "" {
+		seq, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return query, errors.New("invalid cursor")
+		}
+		query.Before = seq
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, errInvalidLimit
+		}
+		query.Limit = min(n, maxEventPage)
+	}
+	return query, nil
+}
+
+// nextCursor is the cursor for the page after events, or empty when there
+// is none.
+func nextCursor(events []hooks.Event, more bool) string {
+	if !more || len(events) == 0 {
+		return ""
+	}
+	return strconv.FormatUint(events[len(events)-1].Seq, 10)
+}
+
+// handleListEvents returns events newest first, filtered by session,
+// project, event name, tool name, time range and text. Results are paged
+// with the returned cursor, and written as NDJSON when format=ndjson or the
+// client accepts application/x-ndjson.
+func (s *Server) handleListEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query, err := parseEventQuery(q, defaultEventPage)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.SessionIDs = listParam(q, "session")
+	// With no session in the project asked for, the result is empty; the
+	// store would read an empty SessionIDs as every session.
+	matched := true
+	if project := q.Get("project"); project != "" {
+		ids := make([]string, 0)
+		for _, sess := range s.sessions.All() {
+			if sess.Project != project {
+				continue
+			}
+			if len(query.SessionIDs) == 0 || slices.Contains(query.SessionIDs, sess.ID) {
+				ids = append(ids, sess.ID)
+			}
+		}
+		query.SessionIDs = ids
+		matched = len(ids) > 0
+	}
+
+	events, more := []hooks.Event{}, false
+	if matched {
+		events, more = s.events.Query(query)
+	}
+	cursor := nextCursor(events, more)
+
+	if q.Get("format") == "ndjson" || (q.Get("format") == "" && strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		if cursor != "" {
+			w.Header().Set("X-Next-Cursor", cursor)
+		}
+		encoder := json.NewEncoder(w)
+		for _, e := range events {
+			if err := encoder.Encode(e); err != nil {
+				return
+			}
+		}
+		return
+	}
+	writeJSON(w, struct {
+		Events     []hooks.Event `json:"events"`
+		NextCursor string        `json:"next_cursor,omitempty"`
+	}{events, cursor})
+}
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"bytes"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/session"
+)
+
+// fragmentDelay is how long changes are collected before they are pushed,
+// so a burst of hooks goes out as one message per session.
+const fragmentDelay = 100 * time.Millisecond
+
+// fragmentBatch is what changed in a session since the last push.
+type fragmentBatch struct {
+	// events are new event IDs, oldest first.
+	events    []string
+	approvals []string
+	resolved  []string
+	files     bool
+	usage     bool
+	// item is set when the session's list item needs rendering again.
+	item bool
+}
+
+// fragmentQueue collects changes per session and flushes them together
+// after fragmentDelay. A session's list item, files and usage are rendered
+// once per flush however often they changed.
+type fragmentQueue struct {
+	mu        sync.Mutex
+	pending   map[string]*fragmentBatch
+	scheduled bool
+	flush     func(map[string]*fragmentBatch)
+}
+
+func newFragmentQueue(flush func(map[string]*fragmentBatch)) *fragmentQueue {
+	return &fragmentQueue{
+		pending: make(map[string]*fragmentBatch),
+		flush:   flush,
+	}
+}
+
+// add records a change to a session and schedules a flush.
+func (q *fragmentQueue) add(sessionID string, change func(*fragmentBatch)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	b, ok := q.pending[sessionID]
+	if !ok {
+		b = &fragmentBatch{}
+		q.pending[sessionID] = b
+	}
+	change(b)
+	if !q.scheduled {
+		q.scheduled = true
+		time.AfterFunc(fragmentDelay, q.run)
+	}
+}
+
+func (q *fragmentQueue) run() {
+	q.mu.Lock()
+	batches := q.pending
+	q.pending = make(map[string]*fragmentBatch)
+	q.scheduled = false
+	q.mu.Unlock()
+	q.flush(batches)
+}
+
+// queueEvent pushes a new event row, and the session's list item, whose
+// last activity changed.
+func (s *Server) queueEvent(e hooks.Event) {
+	s.fragments.add(e.SessionID, func(b *fragmentBatch) {
+		b.events = append(b.events, e.ID)
+		b.item = true
+	})
+}
+
+// queueApproval pushes a new approval card.
+func (s *Server) queueApproval(sessionID, approvalID string) {
+	s.fragments.add(sessionID, func(b *fragmentBatch) {
+		b.approvals = append(b.approvals, approvalID)
+		b.item = true
+	})
+}
+
+// queueApprovalResolved removes an approval card.
+func (s *Server) queueApprovalResolved(sessionID, approvalID string) {
+	s.fragments.add(sessionID, func(b *fragmentBatch) {
+		b.resolved = append(b.resolved, approvalID)
+		b.item = true
+	})
+}
+
+// queueFiles pushes the session's Files tab.
+func (s *Server) queueFiles(sessionID string) {
+	s.fragments.add(sessionID, func(b *fragmentBatch) {
+		b.files = true
+	})
+}
+
+// queueUsage pushes the session's usage and budgets, its cost in the list
+// and the spending totals.
+func (s *Server) queueUsage(sessionID string) {
+	s.fragments.add(sessionID, func(b *fragmentBatch) {
+		b.usage = true
+		b.item = true
+	})
+}
+
+// groupKey is the Key of the feed group that events of a session after
+// seq fall in: the ID of the latest prompt before seq, or the session's
+// start without one. Zero seq finds the latest prompt.
+func (s *Server) groupKey(sessionID string, seq uint64) string {
+	prompts, _ := s.events.Query(hooks.EventQuery{
+		SessionIDs: []string{sessionID},
+		EventNames: []string{"UserPromptSubmit"},
+		Before:     seq,
+		Limit:      1,
+	})
+	if len(prompts) == 0 {
+		return "start-" + sessionID
+	}
+	return prompts[0].ID
+}
+
+// eventFragment places a new event in the feed: a prompt starts a new group
+// at the top, a subagent's event goes in its block and anything else in
+// the latest group.
+type eventFragment struct {
+	SessionID string
+	Group     *eventGroup
+	Target    string
+	Event     eventData
+}
+
+type approvalFragment struct {
+	SessionID string
+	Approval  approvalData
+}
+
+type usageFragment struct {
+	Detail      sessionDetailData
+	ProjectID   string
+	ProjectCost string
+	Footer      usageFooterData
+}
+
+// pushFragments renders each session's changes as htmx out-of-band swaps
+// and broadcasts them as one "fragment" message per session.
+func (s *Server) pushFragments(batches map[string]*fragmentBatch) {
+	for sessionID, b := range batches {
+		sess, ok := s.sessions.Get(sessionID)
+		if !ok {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := s.renderFragments(&buf, sess, b); err != nil {
+			slog.Error("failed to render fragments", "session_id", sessionID, "error", err)
+			continue
+		}
+		s.hub.Broadcast(Message{
+			Type:      "fragment",
+			SessionID: sessionID,
+			Data:      map[string]any{"html": buf.String()},
+		})
+	}
+}
+
+func (s *Server) renderFragments(buf *bytes.Buffer, sess *session.Session, b *fragmentBatch) error {
+	for _, id := range b.events {
+		e, ok := s.events.Get(id)
+		if !ok {
+			continue
+		}
+		f := eventFragment{SessionID: sess.ID, Event: newEventData(e)}
+		switch {
+		case e.EventName == "UserPromptSubmit":
+			f.Group = &eventGroup{Prompt: &f.Event, SessionID: sess.ID, Key: e.ID}
+		case e.AgentID != "":
+			f.Target = "subagent-events-" + e.AgentID
+		default:
+			f.Target = "event-items-" + s.groupKey(sess.ID, e.Seq)
+		}
+		if err := partialTemplates.ExecuteTemplate(buf, "fragment_event", f); err != nil {
+			return err
+		}
+	}
+	for _, id := range b.approvals {
+		p, ok := s.approvals.Get(id)
+		if !ok {
+			continue
+		}
+		f := approvalFragment{SessionID: sess.ID, Approval: s.newApprovalData(sess, p)}
+		if err := partialTemplates.ExecuteTemplate(buf, "fragment_approval", f); err != nil {
+			return err
+		}
+	}
+	for _, id := range b.resolved {
+		if err := partialTemplates.ExecuteTemplate(buf, "fragment_approval_removed", id); err != nil {
+			return err
+		}
+	}
+	if b.files {
+		data := sessionDetailData{Session: sess, Files: newFileData(s.files.Files(sess.ID), sess.Project)}
+		if err := partialTemplates.ExecuteTemplate(buf, "fragment_files", data); err != nil {
+			return err
+		}
+	}
+	if b.usage {
+		f := usageFragment{
+			Detail: sessionDetailData{
+				Session: sess,
+				Usage:   s.newUsageView(s.usage.Session(sess.ID)),
+				Budgets: newBudgetViews(s.budgets(sess)),
+			},
+			ProjectID: pathID(sess.Project),
+			Footer:    s.usageFooter(),
+		}
+		if u := s.newUsageView(s.usage.Project(sess.Project)); u != nil {
+			f.ProjectCost = u.Cost
+		}
+		if err := partialTemplates.ExecuteTemplate(buf, "fragment_usage", f); err != nil {
+			return err
+		}
+	}
+	if b.item {
+		item := sessionItem{Session: sess, OOB: true}
+		if u := s.newUsageView(s.usage.Session(sess.ID)); u != nil {
+			item.Cost = u.Cost
+		}
+		_, item.Tripped = s.breaker.Tripped(sess.ID)
+		if err := partialTemplates.ExecuteTemplate(buf, "session_item", item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
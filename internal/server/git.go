@@ -0,0 +1,75 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/session"
+)
+
+// gitRefreshDelay is how long refresh requests are collected before git is
+// read, so a burst of edits runs git once.
+const gitRefreshDelay = 100 * time.Millisecond
+
+type gitRefreshState int
+
+const (
+	gitRefreshScheduled gitRefreshState = iota
+	gitRefreshRunning
+	// gitRefreshAgain is a running refresh that changes arrived during,
+	// which needs one more once it finishes.
+	gitRefreshAgain
+)
+
+// gitRefresher runs at most one git refresh per session at a time. Requests
+// made while one is scheduled are part of it; requests made while one runs
+// are collapsed into a single refresh after it.
+type gitRefresher struct {
+	mu      sync.Mutex
+	state   map[string]gitRefreshState
+	refresh func(*session.Session)
+}
+
+func newGitRefresher(refresh func(*session.Session)) *gitRefresher {
+	return &gitRefresher{
+		state:   make(map[string]gitRefreshState),
+		refresh: refresh,
+	}
+}
+
+// request asks for a session's git information to be read again.
+func (g *gitRefresher) request(sess *session.Session) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state, ok := g.state[sess.ID]
+	switch {
+	case !ok:
+		g.state[sess.ID] = gitRefreshScheduled
+		time.AfterFunc(gitRefreshDelay, func() { g.run(sess) })
+	case state == gitRefreshRunning:
+		g.state[sess.ID] = gitRefreshAgain
+	}
+}
+
+func (g *gitRefresher) run(sess *session.Session) {
+	g.mu.Lock()
+	g.state[sess.ID] = gitRefreshRunning
+	g.mu.Unlock()
+
+	g.refresh(sess)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.state[sess.ID] == gitRefreshAgain {
+		g.state[sess.ID] = gitRefreshScheduled
+		time.AfterFunc(gitRefreshDelay, func() { g.run(sess) })
+		return
+	}
+	delete(g.state, sess.ID)
+}
+
+// refreshGitInfo reads a session's git information and tells clients.
+func (s *Server) refreshGitInfo(sess *session.Session) {
+	s.git.Refresh(sess.ID, sess.ProjectDir, sess.StartedAt)
+	s.hub.Broadcast(Message{Type: "git_update", SessionID: sess.ID})
+}
@@ -1,28 +1,49 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
-	"path/filepath"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/aliadnani/claudehaus/internal/config"
+	"github.com/aliadnani/claudehaus/internal/gitinfo"
 	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/redact"
 	"github.com/aliadnani/claudehaus/internal/session"
 )
 
 func (s *Server) handleHook(w http.ResponseWriter, r *http.Request) {
 	event := r.PathValue("event")
+	received := time.Now()
 
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
 	var input hooks.HookInput
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+	if err := json.Unmarshal(body, &input); err != nil {
 		slog.Warn("invalid hook request body", "error", err, "event", event)
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
+	// Only the approval flow sees the raw input; everything else, logs
+	// included, gets secrets masked.
+	raw := input
+	input = s.redactInput(input)
+	body = s.redactor.Load().JSON(body)
 
 	slog.Info("hook event received",
 		"event", event,
@@ -32,27 +53,70 @@ func (s *Server) handleHook(w http.ResponseWriter, r *http.Request) {
 
 	sess, exists := s.sessions.Get(input.SessionID)
 	if !exists {
+		project := config.ProjectRoot(input.Cwd)
 		sess = &session.Session{
-			ID:          input.SessionID,
-			ProjectDir:  input.Cwd,
-			Nickname:    filepath.Base(input.Cwd),
-			Status:      session.StatusActive,
-			StartedAt:   time.Now(),
-			LastEventAt: time.Now(),
-		}
-		if meta, ok := s.cfg.Sessions[input.SessionID]; ok {
+			ID:             input.SessionID,
+			ProjectDir:     input.Cwd,
+			Project:        project,
+			Nickname:       s.cfg.ProjectName(project),
+			TranscriptPath: input.TranscriptPath,
+			Status:         session.StatusActive,
+			StartedAt:      time.Now(),
+			LastEventAt:    time.Now(),
+		}
+		if meta, ok := s.cfg.Session(input.SessionID); ok && meta.Nickname != "" {
 			sess.Nickname = meta.Nickname
 		}
+		s.cfg.TouchSessionMeta(input.SessionID)
 		s.sessions.Set(sess)
+		s.applySessionEventLimit(sess)
 		slog.Info("new session created",
 			"session_id", input.SessionID,
 			"nickname", sess.Nickname,
+			"project", sess.Project,
 			"project_dir", sess.ProjectDir)
 	} else {
 		slog.Debug("existing session found", "session_id", input.SessionID, "nickname", sess.Nickname)
 	}
 
 	s.sessions.TouchSession(input.SessionID)
+	if pid := hookClientPID(r); pid > 0 {
+		s.sessions.SetPID(input.SessionID, pid)
+	}
+	s.trackPermissionMode(sess, input.PermissionMode)
+	if input.TranscriptPath != "" {
+		// A resumed session's transcript starts with the history of the
+		// session it resumes, which was counted there.
+		var since time.Time
+		if !exists && input.Source == session.SourceResume {
+			since = sess.StartedAt
+		}
+		go func() {
+			s.updateUsage(sess.ID, sess.Project, input.TranscriptPath, since)
+			// The transcript is complete once the session ends, and its
+			// last usage has been checked against the budgets.
+			if event == "SessionEnd" {
+				s.forgetSession(sess.ID)
+			}
+		}()
+		go s.indexTranscript(sess.ID, sess.Project, input.TranscriptPath)
+	}
+
+	// Attribute tool calls to a running subagent where possible. Calls that
+	// launch subagents always belong to the parent.
+	agentID := ""
+	if input.ToolName != "" && !hooks.IsSubagentTool(input.ToolName) {
+		agentID = s.sessions.AttributeSubagent(input.SessionID, input.AgentID)
+	}
+
+	// record adds this hook's event to the feed and returns its ID.
+	record := func(detail string) string {
+		e := hooks.NewEvent(event, input, body)
+		e.AgentID = agentID
+		e.Time = received
+		e.Detail = detail
+		return s.events.Add(e)
+	}
 
 	s.hub.Broadcast(Message{
 		Type:      "event",
@@ -60,109 +124,219 @@ func (s *Server) handleHook(w http.ResponseWriter, r *http.Request) {
 		Data: map[string]any{
 			"event_name": event,
 			"tool_name":  input.ToolName,
-			"timestamp":  time.Now().Format("15:04:05"),
+			"agent_id":   agentID,
+			"time":       received,
 		},
 	})
 
 	switch event {
 	case "SessionStart":
-		s.events.AddEvent(input.SessionID, "SessionStart", "", "", "Session started")
+		s.sessions.RecordStart(input.SessionID, input.Source, input.TranscriptPath)
+		detail := sessionStartDetail(input.Source)
+
+		// A new session that resumes, clears or compacts earlier work is
+		// linked to the session it continues.
+		if !exists && input.Source != "" && input.Source != session.SourceStartup {
+			if prev, ok := s.sessions.LinkPredecessor(input.SessionID); ok {
+				detail += " (continues " + prev.Nickname + ")"
+				// Carry over a nickname given to the earlier session.
+				if meta, _ := s.cfg.Session(input.SessionID); meta.Nickname == "" && prev.Nickname != s.cfg.ProjectName(prev.Project) {
+					s.sessions.SetNickname(input.SessionID, prev.Nickname)
+				}
+				slog.Info("session linked", "session_id", input.SessionID, "previous_id", prev.ID, "source", input.Source)
+			}
+		}
+
+		record(detail)
 		s.hub.Broadcast(Message{Type: "session_update", SessionID: input.SessionID, Data: map[string]any{"status": "active"}})
-		slog.Info("session started", "session_id", input.SessionID, "nickname", sess.Nickname)
+		slog.Info("session started", "session_id", input.SessionID, "nickname", sess.Nickname, "source", input.Source)
+		w.WriteHeader(http.StatusOK)
+
+	case "PreCompact":
+		detail := "Compacting context"
+		if input.Trigger != "" {
+			detail += " (" + input.Trigger + ")"
+		}
+		if input.CustomInstructions != "" {
+			detail += ": " + input.CustomInstructions
+		}
+		record(detail)
 		w.WriteHeader(http.StatusOK)
 
 	case "SessionEnd":
-		s.events.AddEvent(input.SessionID, "SessionEnd", "", "", "Session ended")
+		record("Session ended")
+		s.sessions.StopAllSubagents(input.SessionID)
 		s.sessions.UpdateStatus(input.SessionID, session.StatusEnded)
+		// With a transcript, this waits for its last read.
+		if input.TranscriptPath == "" {
+			s.forgetSession(input.SessionID)
+		}
 		s.hub.Broadcast(Message{Type: "session_update", SessionID: input.SessionID, Data: map[string]any{"status": "ended"}})
 		slog.Info("session ended", "session_id", input.SessionID, "nickname", sess.Nickname)
 		w.WriteHeader(http.StatusOK)
 
 	case "PermissionRequest":
-		approvalID := generateID()
+		if budget, overBudget := s.worstBudget(sess); overBudget && budget.Step == budgetDeny {
+			record("Denied: " + budget.String())
+			slog.Info("permission request denied by budget", "session_id", input.SessionID, "scope", budget.Scope, "percent", budget.Percent)
+			writeJSON(w, hooks.NewDenyResponse(budgetDenial(budget)))
+			return
+		}
 
-		pending := &hooks.PendingApproval{
-			ID:           approvalID,
-			SessionID:    input.SessionID,
-			CreatedAt:    time.Now(),
-			ToolName:     input.ToolName,
-			ToolInput:    input.ToolInput,
-			Prompt:       input.Prompt,
-			ResponseChan: make(chan hooks.Decision, 1),
+		_, conflict, _ := s.fileConflict(input)
+		decision, ok := s.awaitDecision(r.Context(), input, raw.ToolInput, event, conflict)
+		if !ok {
+			record("Answered elsewhere")
+			return
 		}
 
-		s.approvals.Add(pending)
-		s.sessions.UpdatePending(input.SessionID, true, s.approvals.CountBySession(input.SessionID))
+		var resp hooks.ApprovalResponse
+		detail := decision.Behavior
+		switch decision.Behavior {
+		case "allow":
+			resp = hooks.NewAllowResponse()
+		case "answer":
+			// The answers go back to Claude as tool input, so start from
+			// the unredacted input.
+			updated, err := hooks.AnswerQuestions(raw.ToolInput, decision.Answers)
+			if err != nil {
+				slog.Error("failed to attach answers", "error", err, "session_id", input.SessionID)
+				// Fall back to the terminal prompt.
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			resp = hooks.NewAllowWithInputResponse(updated)
+			detail = "Answered: " + formatAnswers(decision.Answers)
+		default:
+			resp = hooks.NewDenyResponse(decision.Message)
+		}
 
-		slog.Info("permission request pending",
-			"approval_id", approvalID,
-			"session_id", input.SessionID,
-			"tool_name", input.ToolName)
+		record(detail)
+		writeJSON(w, resp)
 
-		s.hub.Broadcast(Message{
-			Type:      "approval_request",
-			SessionID: input.SessionID,
-			Data: map[string]any{
-				"approval_id": approvalID,
-				"tool_name":   input.ToolName,
-				"tool_input":  input.ToolInput,
-			},
-		})
+	case "PreToolUse":
+		budget, overBudget := s.worstBudget(sess)
+		if overBudget && budget.Step == budgetDeny {
+			reason := budgetDenial(budget)
+			record("Denied: " + budget.String())
+			slog.Info("tool call denied by budget", "session_id", input.SessionID, "scope", budget.Scope, "percent", budget.Percent)
+			writeJSON(w, hooks.NewPreToolUseResponse("deny", reason))
+			return
+		}
 
-		// Block until: web UI sends a decision, or Claude Code disconnects
-		// (user answered in terminal / HTTP hook timed out on their side).
-		select {
-		case decision := <-pending.ResponseChan:
-			s.approvals.Remove(approvalID)
-			count := s.approvals.CountBySession(input.SessionID)
-			s.sessions.UpdatePending(input.SessionID, count > 0, count)
-
-			slog.Info("permission request resolved",
-				"approval_id", approvalID,
-				"decision", decision.Behavior,
-				"message", decision.Message)
-
-			s.events.AddEvent(input.SessionID, "PermissionRequest", input.ToolName, string(input.ToolInput),
-				decision.Behavior)
-
-			var resp hooks.ApprovalResponse
-			if decision.Behavior == "allow" {
-				resp = hooks.NewAllowResponse()
-			} else {
-				resp = hooks.NewDenyResponse(decision.Message)
-			}
-			writeJSON(w, resp)
+		if hooks.IsSubagentTool(input.ToolName) {
+			s.startSubagent(input)
+		}
 
-		case <-r.Context().Done():
-			// Client disconnected — user answered in terminal or Claude Code moved on.
-			s.approvals.Remove(approvalID)
-			count := s.approvals.CountBySession(input.SessionID)
-			s.sessions.UpdatePending(input.SessionID, count > 0, count)
+		s.checkBreaker(sess, input)
+		_, tripped := s.breaker.Tripped(sess.ID)
 
-			slog.Info("permission request cancelled (client disconnected)",
-				"approval_id", approvalID)
+		path, warning, others := s.fileConflict(input)
+		if warning != "" {
+			s.warnFileConflict(sess, path, warning, others)
+		}
 
-			s.events.AddEvent(input.SessionID, "PermissionRequest", input.ToolName, string(input.ToolInput),
-				"Answered elsewhere")
+		settings := s.cfg.CurrentSettings()
+		review := s.cfg.ReviewsTool(sess.Project, input.ToolName) ||
+			(warning != "" && settings.ConflictReview) ||
+			(overBudget && budget.Step == budgetReview) ||
+			(tripped && settings.Breaker.Review)
+		if !review {
+			eventID := record("")
+			s.recordFileAccess(input, eventID, false)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
 
-			s.hub.Broadcast(Message{Type: "approval_resolved", Data: map[string]any{"approval_id": approvalID, "decision": "cancelled"}})
+		decision, ok := s.awaitDecision(r.Context(), input, raw.ToolInput, event, warning)
+		if !ok {
+			record("Review abandoned")
+			return
 		}
 
-	case "Stop", "SubagentStop":
-		s.events.AddEvent(input.SessionID, event, "", "", "Task stopped")
+		eventID := record("Reviewed: " + decision.Behavior)
+		if decision.Behavior == "allow" {
+			s.recordFileAccess(input, eventID, false)
+		}
+		writeJSON(w, hooks.NewPreToolUseResponse(decision.Behavior, decision.Message))
+
+	case "SubagentStart":
+		s.sessions.BindSubagent(input.SessionID, input.AgentID, input.AgentType)
+		record("Subagent started: " + input.AgentType)
+		s.hub.Broadcast(Message{Type: "subagent_update", SessionID: input.SessionID, Data: map[string]any{"agent_id": input.AgentID, "status": "running"}})
+		w.WriteHeader(http.StatusOK)
+
+	case "SubagentStop":
+		// Only the subagent finished; the parent session keeps running.
+		s.sessions.BindSubagent(input.SessionID, input.AgentID, input.AgentType)
+		s.stopSubagent(input.SessionID, input.AgentID)
+		record("Subagent stopped")
+		w.WriteHeader(http.StatusOK)
+
+	case "Stop":
+		record("Task stopped")
+		s.sessions.StopAllSubagents(input.SessionID)
 		s.sessions.UpdateStatus(input.SessionID, session.StatusIdle)
 		s.hub.Broadcast(Message{Type: "session_update", SessionID: input.SessionID, Data: map[string]any{"status": "idle"}})
 		slog.Info("session idle", "session_id", input.SessionID, "event", event)
-		w.WriteHeader(http.StatusOK)
+
+		hold := s.cfg.StopHold(sess.Project)
+		if hold <= 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Hold the Stop hook open so a follow-up instruction can be sent
+		// from the web UI. The window ends silently on timeout.
+		ctx, cancel := context.WithTimeout(r.Context(), hold)
+		defer cancel()
+		decision, ok := s.awaitDecision(ctx, input, nil, event, "")
+		if !ok {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		s.sessions.TouchSession(input.SessionID)
+		s.events.AddEvent(input.SessionID, event, "Continued: "+decision.Message)
+		s.hub.Broadcast(Message{Type: "session_update", SessionID: input.SessionID, Data: map[string]any{"status": "active"}})
+		writeJSON(w, hooks.NewStopBlockResponse(decision.Message))
+
+	case "UserPromptSubmit":
+		pinned := s.cfg.TakeContext(input.SessionID, sess.Project)
+		scopes := make([]string, 0, len(pinned))
+		texts := make([]string, 0, len(pinned))
+		for _, c := range pinned {
+			scopes = append(scopes, c.Scope)
+			texts = append(texts, c.Text)
+		}
+		additional := strings.Join(texts, "\n\n")
+
+		s.prompts.Add(input.SessionID, sess.Project, input.Prompt, additional)
+		record(input.Prompt)
+		slog.Info("prompt submitted", "session_id", input.SessionID, "length", len(input.Prompt))
+
+		if additional == "" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		injected, _ := json.Marshal(s.redactor.Load().String(additional))
+		s.events.Add(hooks.Event{
+			SessionID: input.SessionID,
+			EventName: "ContextInjected",
+			ToolInput: injected,
+			Detail:    strings.Join(scopes, ", "),
+		})
+		slog.Info("pinned context injected", "session_id", input.SessionID, "scopes", scopes)
+		writeJSON(w, hooks.NewPromptContextResponse(additional))
 
 	case "Notification":
-		s.events.AddEvent(input.SessionID, "Notification", "", "", input.Message)
+		record(input.Message)
 		s.hub.Broadcast(Message{
 			Type:      "notification",
 			SessionID: input.SessionID,
 			Data: map[string]any{
-				"type":     input.NotificationType,
-				"message":  input.Message,
+				"type":    input.NotificationType,
+				"message": input.Message,
 			},
 		})
 		slog.Info("notification received",
@@ -172,16 +346,222 @@ func (s *Server) handleHook(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 
 	default:
-		// Capture all other events (PreToolUse, PostToolUse, etc.) for the web UI
-		if input.ToolName != "" {
-			s.events.AddEvent(input.SessionID, event, input.ToolName, string(input.ToolInput), "")
-		} else {
-			s.events.AddEvent(input.SessionID, event, "", "", "")
+		if event == "PostToolUse" && hooks.IsSubagentTool(input.ToolName) {
+			s.stopSubagent(input.SessionID, input.ToolUseID)
+		}
+		if event == "PostToolUse" {
+			// Sessions without a PreToolUse hook are only seen here.
+			s.checkBreaker(sess, input)
+		}
+		if event == "PostToolUse" && changesWorktree(input.ToolName) {
+			s.gitRefresh.request(sess)
+		}
+
+		// Capture all other events (PostToolUse, etc.) for the web UI
+		eventID := record("")
+		if event == "PostToolUse" && input.ToolName != "" {
+			s.recordFileAccess(input, eventID, true)
 		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// awaitDecision registers a pending approval for a blocking hook event and
+// waits for a decision from the web UI. rawToolInput is shown on the card
+// instead of the redacted input if configured, and conflict is the caller's
+// file conflict warning, if any. It returns false if ctx ends first: the hook
+// client disconnected (user answered in terminal / hook timed out on their
+// side) or the caller's own deadline passed.
+func (s *Server) awaitDecision(ctx context.Context, input hooks.HookInput, rawToolInput json.RawMessage, event, conflict string) (hooks.Decision, bool) {
+	approvalID := generateID()
+
+	pending := &hooks.PendingApproval{
+		ID:           approvalID,
+		SessionID:    input.SessionID,
+		EventName:    event,
+		CreatedAt:    time.Now(),
+		ToolName:     input.ToolName,
+		ToolInput:    input.ToolInput,
+		Prompt:       input.Prompt,
+		ResponseChan: make(chan hooks.Decision, 1),
+	}
+	if s.cfg.CurrentSettings().Redaction.KeepRawForApprovals && !bytes.Equal(rawToolInput, input.ToolInput) {
+		pending.RawToolInput = rawToolInput
+	}
+	warnings := []string{}
+	if conflict != "" {
+		warnings = append(warnings, "Conflict: "+conflict)
+	}
+	if sess, ok := s.sessions.Get(input.SessionID); ok {
+		if b, ok := s.worstBudget(sess); ok && b.Step >= budgetReview {
+			warnings = append(warnings, "Budget: "+b.String())
+		}
+	}
+	if trip, ok := s.breaker.Tripped(input.SessionID); ok {
+		warnings = append(warnings, "Circuit breaker: "+trip.Reason)
+	}
+	pending.Warning = strings.Join(warnings, ". ")
+
+	s.approvals.Add(pending)
+	s.sessions.UpdatePending(input.SessionID, true, s.approvals.CountBySession(input.SessionID))
+
+	slog.Info("approval pending",
+		"approval_id", approvalID,
+		"event", event,
+		"session_id", input.SessionID,
+		"tool_name", input.ToolName)
+
+	// Large inputs are previewed; the full input is at
+	// /api/approvals/{id}/payload.
+	preview, truncated := s.previewPayload(string(input.ToolInput))
+	s.hub.Broadcast(Message{
+		Type:      "approval_request",
+		SessionID: input.SessionID,
+		Data: map[string]any{
+			"approval_id":          approvalID,
+			"event_name":           event,
+			"tool_name":            input.ToolName,
+			"tool_input":           preview,
+			"tool_input_truncated": truncated,
+		},
+	})
+	s.queueApproval(input.SessionID, approvalID)
+
+	defer func() {
+		s.approvals.Remove(approvalID)
+		count := s.approvals.CountBySession(input.SessionID)
+		s.sessions.UpdatePending(input.SessionID, count > 0, count)
+		s.queueApprovalResolved(input.SessionID, approvalID)
+	}()
+
+	select {
+	case decision := <-pending.ResponseChan:
+		slog.Info("approval resolved",
+			"approval_id", approvalID,
+			"event", event,
+			"decision", decision.Behavior,
+			"message", decision.Message)
+		return decision, true
+
+	case <-ctx.Done():
+		slog.Info("approval cancelled",
+			"approval_id", approvalID,
+			"event", event,
+			"reason", ctx.Err())
+		s.hub.Broadcast(Message{Type: "approval_resolved", SessionID: input.SessionID, Data: map[string]any{"approval_id": approvalID, "decision": "cancelled"}})
+		return hooks.Decision{}, false
+	}
+}
+
+// hookClientPID returns the Claude Code PID reported by the hook script.
+// It is only trusted from loopback clients, since a PID from another host
+// means nothing to the liveness monitor.
+func hookClientPID(r *http.Request) int {
+	header := r.Header.Get("X-Claude-PID")
+	if header == "" {
+		return 0
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return 0
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return 0
+	}
+	pid, err := strconv.Atoi(header)
+	if err != nil || pid <= 1 {
+		return 0
+	}
+	return pid
+}
+
+// changesWorktree reports whether a tool can change files or git state.
+func changesWorktree(toolName string) bool {
+	switch toolName {
+	case "Bash", "Edit", "MultiEdit", "Write", "NotebookEdit":
+		return true
+	}
+	return false
+}
+
+// sessionStartDetail describes a SessionStart by its source.
+func sessionStartDetail(source string) string {
+	switch source {
+	case session.SourceResume:
+		return "Session resumed"
+	case session.SourceClear:
+		return "Session cleared"
+	case session.SourceCompact:
+		return "Session continued after compaction"
+	default:
+		return "Session started"
+	}
+}
+
+// trackPermissionMode records a change in the session's permission mode and
+// raises an alert when it enters bypassPermissions where that is enabled.
+func (s *Server) trackPermissionMode(sess *session.Session, mode string) {
+	previous, changed := s.sessions.SetPermissionMode(sess.ID, mode)
+	if !changed {
+		return
+	}
+
+	detail := "Permission mode: " + mode
+	if previous != "" {
+		detail = "Permission mode: " + previous + " → " + mode
+	}
+	s.events.AddEvent(sess.ID, "PermissionMode", detail)
+	s.hub.Broadcast(Message{Type: "session_update", SessionID: sess.ID, Data: map[string]any{"permission_mode": mode}})
+	slog.Info("permission mode changed", "session_id", sess.ID, "from", previous, "to", mode)
+
+	if mode == session.ModeBypassPermissions && s.cfg.AlertsOnBypass(sess.Project) {
+		alert := newAlert(AlertBypassPermissions, sess, sess.Nickname+" entered bypassPermissions mode")
+		alert.Details = map[string]any{"previous_mode": previous}
+		s.raiseAlert(alert)
+	}
+}
+
+func (s *Server) startSubagent(input hooks.HookInput) {
+	var taskInput hooks.SubagentInput
+	_ = json.Unmarshal(input.ToolInput, &taskInput)
+
+	id := input.ToolUseID
+	if id == "" {
+		id = generateID()
+	}
+	s.sessions.StartSubagent(input.SessionID, session.Subagent{
+		ID:          id,
+		Type:        taskInput.SubagentType,
+		Description: taskInput.Description,
+	})
+	slog.Info("subagent started",
+		"session_id", input.SessionID,
+		"subagent_id", id,
+		"type", taskInput.SubagentType)
+	s.hub.Broadcast(Message{Type: "subagent_update", SessionID: input.SessionID, Data: map[string]any{"subagent_id": id, "status": "running"}})
+}
+
+func (s *Server) stopSubagent(sessionID, id string) {
+	agent, ok := s.sessions.StopSubagent(sessionID, id)
+	if !ok {
+		return
+	}
+	slog.Info("subagent stopped",
+		"session_id", sessionID,
+		"subagent_id", agent.ID,
+		"duration", agent.StoppedAt.Sub(agent.StartedAt))
+	s.hub.Broadcast(Message{Type: "subagent_update", SessionID: sessionID, Data: map[string]any{"subagent_id": agent.ID, "status": "stopped"}})
+}
+
+func formatAnswers(answers map[string]string) string {
+	parts := make([]string, 0, len(answers))
+	for _, a := range answers {
+		parts = append(parts, a)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
 func generateID() string {
 	b := make([]byte, 8)
 	_, _ = rand.Read(b)
@@ -200,7 +580,21 @@ func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "session not found", http.StatusNotFound)
 		return
 	}
-	writeJSON(w, sess)
+	writeJSON(w, struct {
+		*session.Session
+		Git     *gitinfo.Info  `json:"git"`
+		Usage   usageSummary   `json:"usage"`
+		Budgets []budgetStatus `json:"budgets"`
+	}{sess, s.git.Get(sess.ID, sess.ProjectDir, sess.StartedAt), s.summarizeUsage(s.usage.Session(sess.ID)), s.budgets(sess)})
+}
+
+func (s *Server) handleListFiles(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.sessions.Get(id); !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, s.files.Files(id))
 }
 
 func (s *Server) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
@@ -219,28 +613,282 @@ func (s *Server) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sess.Nickname = req.Nickname
-	s.sessions.Set(sess)
+	s.sessions.SetNickname(id, req.Nickname)
 
-	s.cfg.Sessions[id] = struct {
-		Nickname string `json:"nickname"`
-	}{Nickname: req.Nickname}
-	_ = s.cfg.Save()
+	_ = s.cfg.SetSessionNickname(id, req.Nickname)
 
 	writeJSON(w, sess)
 }
 
+// decodeContextRequest reads a pinned context edit from a JSON body or an
+// htmx form post.
+func decodeContextRequest(r *http.Request) (projectDir, text, mode string, err error) {
+	if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		var req struct {
+			ProjectDir string `json:"project_dir"`
+			Text       string `json:"text"`
+			Mode       string `json:"mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return "", "", "", err
+		}
+		return req.ProjectDir, strings.TrimSpace(req.Text), req.Mode, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return "", "", "", err
+	}
+	return r.FormValue("project_dir"), strings.TrimSpace(r.FormValue("text")), r.FormValue("mode"), nil
+}
+
+func (s *Server) handleUpdateSessionContext(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := s.sessions.Get(id); !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	_, text, mode, err := decodeContextRequest(r)
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.cfg.SetSessionContext(id, text, mode); err != nil {
+		http.Error(w, "failed to save context", http.StatusInternalServerError)
+		return
+	}
+	s.recordContextChange(r, "session", id, text, mode)
+
+	meta, _ := s.cfg.Session(id)
+	writeJSON(w, meta.Context)
+}
+
+func (s *Server) handleUpdateProjectContext(w http.ResponseWriter, r *http.Request) {
+	projectDir, text, mode, err := decodeContextRequest(r)
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if projectDir == "" {
+		http.Error(w, "missing project_dir", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.cfg.SetProjectContext(projectDir, text, mode); err != nil {
+		http.Error(w, "failed to save context", http.StatusInternalServerError)
+		return
+	}
+	s.recordContextChange(r, "project", projectDir, text, mode)
+
+	writeJSON(w, s.cfg.Project(projectDir).Context)
+}
+
+func (s *Server) recordContextChange(r *http.Request, scope, target, text, mode string) {
+	changedBy := s.cfg.TokenName(extractToken(r))
+	if text == "" {
+		mode = ""
+	}
+	s.audit.Record(hooks.ContextChange{
+		Timestamp: time.Now(),
+		Scope:     scope,
+		Target:    target,
+		Text:      text,
+		Mode:      mode,
+		ChangedBy: changedBy,
+	})
+	slog.Info("pinned context updated",
+		"scope", scope,
+		"target", target,
+		"mode", mode,
+		"cleared", text == "",
+		"changed_by", changedBy)
+}
+
+func (s *Server) handleContextAudit(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.audit.List(r.URL.Query().Get("target")))
+}
+
+func (s *Server) handleListPrompts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := hooks.PromptQuery{
+		SessionID:  q.Get("session"),
+		ProjectDir: q.Get("project"),
+		Text:       q.Get("q"),
+		Limit:      100,
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = n
+	}
+	if id := r.PathValue("id"); id != "" {
+		query.SessionID = id
+	}
+	writeJSON(w, s.prompts.Search(query))
+}
+
+type projectSummary struct {
+	Dir      string             `json:"dir"`
+	Name     string             `json:"name"`
+	Settings config.ProjectMeta `json:"settings"`
+	Sessions []string           `json:"sessions"`
+}
+
+// handleListProjects lists configured projects and projects with sessions
+// in memory.
+func (s *Server) handleListProjects(w http.ResponseWriter, r *http.Request) {
+	projects := make(map[string]*projectSummary)
+	add := func(dir string) *projectSummary {
+		p, ok := projects[dir]
+		if !ok {
+			p = &projectSummary{
+				Dir:      dir,
+				Name:     s.cfg.ProjectName(dir),
+				Settings: s.cfg.Project(dir),
+				Sessions: []string{},
+			}
+			projects[dir] = p
+		}
+		return p
+	}
+	for dir := range s.cfg.ListProjects() {
+		add(dir)
+	}
+	for _, sess := range s.sessions.All() {
+		p := add(sess.Project)
+		p.Sessions = append(p.Sessions, sess.ID)
+	}
+
+	result := make([]*projectSummary, 0, len(projects))
+	for _, p := range projects {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	writeJSON(w, result)
+}
+
+func (s *Server) handleUpdateProject(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ProjectDir       string    `json:"project_dir"`
+		Name             *string   `json:"name,omitempty"`
+		Color            *string   `json:"color,omitempty"`
+		ReviewTools      *[]string `json:"review_tools,omitempty"`
+		StopHoldSeconds  *int      `json:"stop_hold_seconds,omitempty"`
+		AlertOnBypass    *bool     `json:"alert_on_bypass,omitempty"`
+		EventsPerSession *int      `json:"events_per_session,omitempty"`
+	}
+	if r.Header.Get("HX-Request") == "" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+	} else {
+		// The project form in the session view only edits name and color.
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		req.ProjectDir = r.FormValue("project_dir")
+		if r.Form.Has("name") {
+			name := strings.TrimSpace(r.FormValue("name"))
+			req.Name = &name
+		}
+		if r.Form.Has("color") {
+			color := r.FormValue("color")
+			req.Color = &color
+		}
+	}
+	if req.ProjectDir == "" {
+		http.Error(w, "missing project_dir", http.StatusBadRequest)
+		return
+	}
+
+	if req.StopHoldSeconds != nil && *req.StopHoldSeconds < 0 {
+		http.Error(w, "stop_hold_seconds must not be negative", http.StatusBadRequest)
+		return
+	}
+	if req.EventsPerSession != nil && *req.EventsPerSession < 0 {
+		http.Error(w, "events_per_session must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	oldName := s.cfg.ProjectName(req.ProjectDir)
+	meta, err := s.cfg.UpdateProject(req.ProjectDir, func(p *config.ProjectMeta) {
+		if req.Name != nil {
+			p.Name = *req.Name
+		}
+		if req.Color != nil {
+			p.Color = *req.Color
+		}
+		if req.ReviewTools != nil {
+			p.ReviewTools = *req.ReviewTools
+		}
+		if req.StopHoldSeconds != nil {
+			p.StopHoldSeconds = *req.StopHoldSeconds
+		}
+		if req.AlertOnBypass != nil {
+			p.AlertOnBypass = *req.AlertOnBypass
+		}
+		if req.EventsPerSession != nil {
+			p.EventsPerSession = *req.EventsPerSession
+		}
+	})
+	if err != nil {
+		http.Error(w, "failed to save project", http.StatusInternalServerError)
+		return
+	}
+
+	if req.EventsPerSession != nil {
+		s.applyEventLimits()
+	}
+
+	// Sessions still using the inherited project name follow the rename.
+	if newName := s.cfg.ProjectName(req.ProjectDir); newName != oldName {
+		s.sessions.RenameInherited(req.ProjectDir, oldName, newName)
+		s.hub.Broadcast(Message{Type: "session_update", Data: map[string]any{"project": req.ProjectDir}})
+	}
+
+	slog.Info("project settings updated",
+		"project_dir", req.ProjectDir,
+		"name", meta.Name,
+		"review_tools", meta.ReviewTools,
+		"stop_hold_seconds", meta.StopHoldSeconds,
+		"alert_on_bypass", meta.AlertOnBypass,
+		"events_per_session", meta.EventsPerSession)
+	writeJSON(w, meta)
+}
+
+// validDecision reports whether a decision can answer a pending event:
+// allow, deny or ask for PreToolUse, allow, deny or answer for
+// PermissionRequest, and continue for Stop.
+func validDecision(event, decision string) bool {
+	switch event {
+	case "PreToolUse":
+		return decision == "allow" || decision == "deny" || decision == "ask"
+	case "PermissionRequest":
+		return decision == "allow" || decision == "deny" || decision == "answer"
+	case "Stop":
+		return decision == "continue"
+	}
+	return false
+}
+
 func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 
 	var decision, message string
+	var answers map[string]string
 
 	// HTMX sends hx-vals as JSON when using curly brace syntax
 	contentType := r.Header.Get("Content-Type")
 	if strings.Contains(contentType, "application/json") {
 		var req struct {
-			Decision string `json:"decision"`
-			Message  string `json:"message"`
+			Decision string            `json:"decision"`
+			Message  string            `json:"message"`
+			Answers  map[string]string `json:"answers"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			slog.Warn("invalid approval request", "error", err, "approval_id", id)
@@ -249,6 +897,7 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 		}
 		decision = req.Decision
 		message = req.Message
+		answers = req.Answers
 	} else {
 		// Fallback to form data
 		if err := r.ParseForm(); err != nil {
@@ -273,9 +922,38 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !validDecision(pending.EventName, decision) {
+		slog.Warn("invalid decision", "approval_id", id, "event", pending.EventName, "decision", decision)
+		http.Error(w, "invalid decision for "+pending.EventName+": "+decision, http.StatusBadRequest)
+		return
+	}
+
+	if pending.EventName == "Stop" && strings.TrimSpace(message) == "" {
+		http.Error(w, "missing instruction", http.StatusBadRequest)
+		return
+	}
+
+	if decision == "answer" {
+		questions := hooks.ParseQuestions(pending.ToolName, pending.ToolInput)
+		if questions == nil {
+			http.Error(w, "approval has no questions", http.StatusBadRequest)
+			return
+		}
+		if answers == nil {
+			answers = formAnswers(r, questions)
+		}
+		for _, q := range questions {
+			if strings.TrimSpace(answers[q.Question]) == "" {
+				http.Error(w, "missing answer: "+q.Question, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
 	decisionStruct := hooks.Decision{
 		Behavior: decision,
 		Message:  message,
+		Answers:  answers,
 	}
 
 	select {
@@ -285,7 +963,8 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 			"decision", decision,
 			"message", message)
 		s.hub.Broadcast(Message{
-			Type: "approval_resolved",
+			Type:      "approval_resolved",
+			SessionID: pending.SessionID,
 			Data: map[string]any{
 				"approval_id": id,
 				"decision":    decision,
@@ -298,33 +977,115 @@ func (s *Server) handleApproval(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// formAnswers collects answers from the question form in the session view.
+// Question i is submitted as one or more "q<i>" values (chosen options) plus
+// an optional "q<i>_other" free-text value.
+func formAnswers(r *http.Request, questions []hooks.Question) map[string]string {
+	answers := make(map[string]string, len(questions))
+	for i, q := range questions {
+		key := fmt.Sprintf("q%d", i)
+		values := r.Form[key]
+		if other := strings.TrimSpace(r.FormValue(key + "_other")); other != "" {
+			values = append(values, other)
+		}
+		answers[q.Question] = strings.Join(values, ", ")
+	}
+	return answers
+}
+
 func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, s.cfg.Settings)
+	writeJSON(w, s.cfg.CurrentSettings())
 }
 
 func (s *Server) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
 	var settings struct {
-		ApprovalTimeoutSeconds  *int    `json:"approval_timeout_seconds,omitempty"`
-		ApprovalTimeoutBehavior *string `json:"approval_timeout_behavior,omitempty"`
+		ApprovalTimeoutSeconds  *int              `json:"approval_timeout_seconds,omitempty"`
+		ApprovalTimeoutBehavior *string           `json:"approval_timeout_behavior,omitempty"`
+		SessionIdleSeconds      *int              `json:"session_idle_seconds,omitempty"`
+		SessionStaleSeconds     *int              `json:"session_stale_seconds,omitempty"`
+		AlertOnBypass           *bool             `json:"alert_on_bypass,omitempty"`
+		ConflictWindowSeconds   *int              `json:"conflict_window_seconds,omitempty"`
+		ConflictReview          *bool             `json:"conflict_review,omitempty"`
+		Webhooks                *[]config.Webhook `json:"webhooks,omitempty"`
+		Breaker                 *config.Breaker   `json:"breaker,omitempty"`
+		Redaction               *config.Redaction `json:"redaction,omitempty"`
+		PayloadPreviewBytes     *int              `json:"payload_preview_bytes,omitempty"`
+		PayloadMaxBytes         *int              `json:"payload_max_bytes,omitempty"`
+		EventsPerSession        *int              `json:"events_per_session,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
 		http.Error(w, "invalid request", http.StatusBadRequest)
 		return
 	}
-
-	if settings.ApprovalTimeoutSeconds != nil {
-		s.cfg.Settings.ApprovalTimeoutSeconds = *settings.ApprovalTimeoutSeconds
+	if settings.Redaction != nil {
+		if _, err := redact.New(settings.Redaction.Patterns); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
-	if settings.ApprovalTimeoutBehavior != nil {
-		s.cfg.Settings.ApprovalTimeoutBehavior = *settings.ApprovalTimeoutBehavior
+	if settings.Webhooks != nil {
+		for _, hook := range *settings.Webhooks {
+			if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				http.Error(w, "invalid webhook url: "+hook.URL, http.StatusBadRequest)
+				return
+			}
+		}
 	}
 
-	if err := s.cfg.Save(); err != nil {
+	err := s.cfg.UpdateSettings(func(cfg *config.Settings) {
+		if settings.ApprovalTimeoutSeconds != nil {
+			cfg.ApprovalTimeoutSeconds = *settings.ApprovalTimeoutSeconds
+		}
+		if settings.ApprovalTimeoutBehavior != nil {
+			cfg.ApprovalTimeoutBehavior = *settings.ApprovalTimeoutBehavior
+		}
+		if settings.SessionIdleSeconds != nil {
+			cfg.SessionIdleSeconds = *settings.SessionIdleSeconds
+		}
+		if settings.SessionStaleSeconds != nil {
+			cfg.SessionStaleSeconds = *settings.SessionStaleSeconds
+		}
+		if settings.AlertOnBypass != nil {
+			cfg.AlertOnBypass = *settings.AlertOnBypass
+		}
+		if settings.ConflictWindowSeconds != nil {
+			cfg.ConflictWindowSeconds = *settings.ConflictWindowSeconds
+		}
+		if settings.ConflictReview != nil {
+			cfg.ConflictReview = *settings.ConflictReview
+		}
+		if settings.Webhooks != nil {
+			cfg.Webhooks = *settings.Webhooks
+		}
+		if settings.Breaker != nil {
+			cfg.Breaker = *settings.Breaker
+		}
+		if settings.Redaction != nil {
+			cfg.Redaction = *settings.Redaction
+		}
+		if settings.PayloadPreviewBytes != nil {
+			cfg.PayloadPreviewBytes = *settings.PayloadPreviewBytes
+		}
+		if settings.PayloadMaxBytes != nil {
+			cfg.PayloadMaxBytes = *settings.PayloadMaxBytes
+		}
+		if settings.EventsPerSession != nil {
+			cfg.EventsPerSession = *settings.EventsPerSession
+		}
+	})
+	if err != nil {
 		http.Error(w, "failed to save settings", http.StatusInternalServerError)
 		return
 	}
+	if settings.Redaction != nil {
+		s.redactor.Store(s.newRedactor())
+	}
+	s.applyPayloadLimits()
+	if settings.EventsPerSession != nil {
+		s.applyEventLimits()
+	}
 
-	writeJSON(w, s.cfg.Settings)
+	writeJSON(w, s.cfg.CurrentSettings())
 }
 
 func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
@@ -361,8 +1122,6 @@ func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-
-
 func (s *Server) handleVerifyToken(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Token string `json:"token"`
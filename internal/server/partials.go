@@ -1,12 +1,23 @@
 package server
 
 import (
+	"hash/fnv"
 	"html/template"
+	"maps"
 	"net/http"
+	"net/url"
+	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aliadnani/claudehaus/internal/config"
+	"github.com/aliadnani/claudehaus/internal/gitinfo"
+	"github.com/aliadnani/claudehaus/internal/hooks"
 	"github.com/aliadnani/claudehaus/internal/session"
+	"github.com/aliadnani/claudehaus/internal/usage"
 )
 
 var partialTemplates *template.Template
@@ -25,8 +36,10 @@ func statusPriority(s session.Status) int {
 		return 0
 	case session.StatusIdle:
 		return 1
-	default:
+	case session.StatusStale:
 		return 2
+	default:
+		return 3
 	}
 }
 
@@ -50,31 +63,445 @@ func (s *Server) handlePartialSessions(w http.ResponseWriter, r *http.Request) {
 		return filtered[i].LastEventAt.After(filtered[j].LastEventAt)
 	})
 
+	data := sessionsData{
+		Groups: s.groupByProject(filtered),
+		Footer: s.usageFooter(),
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := partialTemplates.ExecuteTemplate(w, "sessions", filtered); err != nil {
+	if err := partialTemplates.ExecuteTemplate(w, "sessions", data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+type sessionsData struct {
+	Groups []*projectGroup
+	Footer usageFooterData
+}
+
+// usageFooterData is the spending shown below the session list.
+type usageFooterData struct {
+	TodayCost string
+	WeekCost  string
+	// DailyBudget is the daily spending limit, empty without one.
+	DailyBudget string
+}
+
+func (s *Server) usageFooter() usageFooterData {
+	today := time.Now().Format(time.DateOnly)
+	week := time.Now().AddDate(0, 0, -6).Format(time.DateOnly)
+	var weekCost float64
+	for _, d := range s.usage.Daily(week, "") {
+		weekCost += s.priceTable().Cost(d.Models)
+	}
+	data := usageFooterData{
+		TodayCost: formatCost(s.priceTable().Cost(s.usage.Date(today))),
+		WeekCost:  formatCost(weekCost),
+	}
+	if limit := s.cfg.CurrentSettings().Budgets.DailyUSD; limit > 0 {
+		data.DailyBudget = formatCost(limit)
+	}
+	return data
+}
+
+type projectGroup struct {
+	// ID identifies the project in element IDs.
+	ID       string
+	Dir      string
+	Name     string
+	Color    string
+	Sessions []*session.Session
+	// Cost is the project's total cost and SessionCosts each session's,
+	// keyed by session ID. Both are empty without usage.
+	Cost         string
+	SessionCosts map[string]string
+	// Tripped holds the sessions whose circuit breaker is tripped.
+	Tripped map[string]bool
+}
+
+// sessionItem is a session in the sidebar list.
+type sessionItem struct {
+	*session.Session
+	Cost    string
+	Tripped bool
+	// OOB is set when the item is pushed on its own, to replace the one
+	// shown.
+	OOB bool
+}
+
+// Item returns the list item for one of the group's sessions.
+func (g *projectGroup) Item(sess *session.Session) sessionItem {
+	return sessionItem{Session: sess, Cost: g.SessionCosts[sess.ID], Tripped: g.Tripped[sess.ID]}
+}
+
+// usageView is token usage formatted for display.
+type usageView struct {
+	Cost          string
+	Input         string
+	Output        string
+	CacheCreation string
+	CacheRead     string
+	Models        []modelUsageView
+}
+
+type modelUsageView struct {
+	Model  string
+	Cost   string
+	Tokens string
+}
+
+func (s *Server) newUsageView(byModel map[string]usage.Tokens) *usageView {
+	summary := s.summarizeUsage(byModel)
+	if summary.Tokens.Total() == 0 {
+		return nil
+	}
+	view := &usageView{
+		Cost:          formatCost(summary.Cost),
+		Input:         formatTokens(summary.Tokens.Input),
+		Output:        formatTokens(summary.Tokens.Output),
+		CacheCreation: formatTokens(summary.Tokens.CacheCreation),
+		CacheRead:     formatTokens(summary.Tokens.CacheRead),
+	}
+	for _, model := range slices.Sorted(maps.Keys(byModel)) {
+		tokens := byModel[model]
+		view.Models = append(view.Models, modelUsageView{
+			Model:  model,
+			Cost:   formatCost(s.priceTable().Cost(map[string]usage.Tokens{model: tokens})),
+			Tokens: formatTokens(tokens.Total()),
+		})
+	}
+	return view
+}
+
+// groupByProject groups sorted sessions by project, ordering projects by
+// their first (highest priority, most recent) session.
+func (s *Server) groupByProject(sessions []*session.Session) []*projectGroup {
+	groups := make([]*projectGroup, 0)
+	byDir := make(map[string]*projectGroup)
+	for _, sess := range sessions {
+		g, ok := byDir[sess.Project]
+		if !ok {
+			g = &projectGroup{
+				ID:           pathID(sess.Project),
+				Dir:          sess.Project,
+				Name:         s.cfg.ProjectName(sess.Project),
+				Color:        s.cfg.Project(sess.Project).Color,
+				SessionCosts: make(map[string]string),
+				Tripped:      make(map[string]bool),
+			}
+			if u := s.newUsageView(s.usage.Project(sess.Project)); u != nil {
+				g.Cost = u.Cost
+			}
+			byDir[sess.Project] = g
+			groups = append(groups, g)
+		}
+		g.Sessions = append(g.Sessions, sess)
+		if u := s.newUsageView(s.usage.Session(sess.ID)); u != nil {
+			g.SessionCosts[sess.ID] = u.Cost
+		}
+		if _, tripped := s.breaker.Tripped(sess.ID); tripped {
+			g.Tripped[sess.ID] = true
+		}
+	}
+	return groups
+}
+
 type sessionDetailData struct {
-	Session   any
-	Approvals []approvalData
-	Events    []eventData
+	Session      any
+	Previous     *sessionBoundary
+	Git          *gitinfo.Info
+	Files        []fileData
+	Usage        *usageView
+	Budgets      []budgetView
+	Breaker      *session.Trip
+	BudgetForm   budgetFormData
+	Project      projectGroup
+	ReviewTools  []string
+	ContextForms []contextFormData
+	Approvals    []approvalData
+	Feed         eventFeed
+	Filter       eventFilter
+	EventNames   []string
+}
+
+// budgetView is spending against a budget formatted for display.
+type budgetView struct {
+	Scope   string
+	Spent   string
+	Limit   string
+	Percent int
+	// Width is Percent capped for the progress bar.
+	Width int
+	Step  string
+}
+
+func newBudgetViews(budgets []budgetStatus) []budgetView {
+	views := make([]budgetView, 0, len(budgets))
+	for _, b := range budgets {
+		views = append(views, budgetView{
+			Scope:   b.Scope,
+			Spent:   formatCost(b.Spent),
+			Limit:   formatCost(b.Limit),
+			Percent: b.Percent,
+			Width:   min(b.Percent, 100),
+			Step:    b.Step.String(),
+		})
+	}
+	return views
+}
+
+// budgetFormData holds the current limits for the budget form. Empty
+// fields mean no budget.
+type budgetFormData struct {
+	SessionUSD string
+	ProjectUSD string
+	DailyUSD   string
+}
+
+func formatBudget(limit float64) string {
+	if limit <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(limit, 'f', -1, 64)
+}
+
+type contextFormData struct {
+	ID         string
+	Scope      string
+	Action     string
+	ProjectDir string
+	Context    *config.PinnedContext
 }
 
 type approvalData struct {
 	ID        string
+	EventName string
+	ExpiresAt int64
 	ToolName  string
 	ToolInput string
-	Prompt    string
+	// PayloadSize is set when ToolInput is a preview.
+	PayloadSize string
+	// Unredacted is set when the input with its secrets can be fetched
+	// from the approval's payload. Cards are broadcast and replayed, so
+	// ToolInput itself is always redacted.
+	Unredacted bool
+	Prompt     string
+	Warning    string
+	Questions  []hooks.Question
+}
+
+// eventGroup is a user prompt and the events that followed it, newest first.
+// Prompt is nil for events that precede the oldest prompt in view.
+type eventGroup struct {
+	Prompt    *eventData
+	Items     []feedItem
+	SessionID string
+	// Key identifies the group for new events pushed to the feed: the ID
+	// of its prompt, or of the session's start before its first prompt.
+	// It is empty on groups that take no new events, such as in a
+	// filtered feed.
+	Key string
+	// Boundary is set on the newest group of each earlier session the
+	// viewed session continues.
+	Boundary *sessionBoundary
+}
+
+type sessionBoundary struct {
+	ID        string
+	Nickname  string
+	Source    string
+	StartedAt string
+}
+
+func newSessionBoundary(sess *session.Session) *sessionBoundary {
+	return &sessionBoundary{
+		ID:        sess.ID,
+		Nickname:  sess.Nickname,
+		Source:    sess.Source,
+		StartedAt: sess.StartedAt.Format("Jan 2 15:04"),
+	}
+}
+
+// feedItem is either a single parent event or a collapsible subagent block
+// holding the events attributed to that subagent.
+type feedItem struct {
+	Event    *eventData
+	Subagent *subagentData
+	Events   []eventData
+}
+
+type subagentData struct {
+	ID          string
+	Type        string
+	Description string
+	StartedAt   string
+	StoppedAt   string
+	Running     bool
 }
 
 type eventData struct {
-	Timestamp string
-	EventName string
-	ToolName  string
-	Detail    string
-	ToolInput string
+	ID string
+	// Time is the server's local time of day, shown until the browser
+	// renders DateTime in its own time zone.
+	Time         string
+	DateTime     string
+	EventName    string
+	ToolName     string
+	Detail       string
+	ToolInput    string
+	ToolResponse string
+	// PayloadSize is set when the tool input or response is a preview,
+	// giving the size of the full content.
+	PayloadSize string
+	// Latency is how long a completed tool call took.
+	Latency   string
+	AgentID   string
+	SessionID string
+}
+
+// fileData is a file in the session's Files tab.
+type fileData struct {
+	ID          string
+	Path        string
+	FullPath    string
+	Reads       int
+	Writes      int
+	Created     bool
+	LastTouched string
+	Accesses    []fileAccessData
+}
+
+type fileAccessData struct {
+	At      string
+	Tool    string
+	EventID string
+	Pending bool
+	Diff    []diffLine
+}
+
+type diffLine struct {
+	Kind string // "add", "del" or "sep"
+	Text string
+}
+
+// newFileData prepares file records for display, with paths relative to
+// the project and each file's accesses newest first.
+func newFileData(records []session.FileRecord, project string) []fileData {
+	files := make([]fileData, 0, len(records))
+	for _, rec := range records {
+		f := fileData{
+			ID:          pathID(rec.Path),
+			Path:        rec.Path,
+			FullPath:    rec.Path,
+			Reads:       rec.Reads,
+			Writes:      rec.Writes,
+			Created:     rec.Created,
+			LastTouched: rec.LastTouched.Format("15:04:05"),
+		}
+		if rel, err := filepath.Rel(project, rec.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			f.Path = rel
+		}
+		for i := len(rec.Accesses) - 1; i >= 0; i-- {
+			a := rec.Accesses[i]
+			access := fileAccessData{
+				At:      a.At.Format("15:04:05"),
+				Tool:    a.Tool,
+				Pending: !a.Completed,
+				Diff:    parseDiff(a.Diff),
+			}
+			if len(a.EventIDs) > 0 {
+				access.EventID = a.EventIDs[len(a.EventIDs)-1]
+			}
+			f.Accesses = append(f.Accesses, access)
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// pathID returns a stable element ID suffix for a file path, so open file
+// entries stay open across re-renders as the list is re-sorted.
+func pathID(path string) string {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+func parseDiff(diff string) []diffLine {
+	if diff == "" {
+		return nil
+	}
+	lines := make([]diffLine, 0)
+	for line := range strings.SplitSeq(strings.TrimSuffix(diff, "\n"), "\n") {
+		kind := "sep"
+		switch {
+		case strings.HasPrefix(line, "+"):
+			kind = "add"
+		case strings.HasPrefix(line, "-"):
+			kind = "del"
+		}
+		lines = append(lines, diffLine{Kind: kind, Text: line})
+	}
+	return lines
+}
+
+// groupByPrompt splits a newest-first event list into groups headed by the
+// UserPromptSubmit event that started them. Groups never span sessions.
+// Within a group, events attributed to a subagent are collected into one
+// block, placed where the subagent's newest event appears.
+func groupByPrompt(events []eventData, subagents map[string]session.Subagent) []eventGroup {
+	groups := make([]eventGroup, 0)
+	current := eventGroup{}
+	blocks := make(map[string]int)
+	for i := range events {
+		e := &events[i]
+		if e.SessionID != current.SessionID {
+			if len(current.Items) > 0 {
+				groups = append(groups, current)
+			}
+			current = eventGroup{SessionID: e.SessionID}
+			blocks = make(map[string]int)
+		}
+		switch {
+		case e.EventName == "UserPromptSubmit":
+			current.Prompt = e
+			groups = append(groups, current)
+			current = eventGroup{SessionID: e.SessionID}
+			blocks = make(map[string]int)
+		case e.AgentID != "":
+			idx, ok := blocks[e.AgentID]
+			if !ok {
+				idx = len(current.Items)
+				blocks[e.AgentID] = idx
+				current.Items = append(current.Items, feedItem{Subagent: newSubagentData(e.AgentID, subagents)})
+			}
+			current.Items[idx].Events = append(current.Items[idx].Events, *e)
+		default:
+			current.Items = append(current.Items, feedItem{Event: e})
+		}
+	}
+	if len(current.Items) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+func newSubagentData(id string, subagents map[string]session.Subagent) *subagentData {
+	data := &subagentData{ID: id, Type: "subagent"}
+	a, ok := subagents[id]
+	if !ok {
+		return data
+	}
+	if a.Type != "" {
+		data.Type = a.Type
+	}
+	data.Description = a.Description
+	data.StartedAt = a.StartedAt.Format("15:04:05")
+	data.Running = a.Running()
+	if !a.Running() {
+		data.StoppedAt = a.StoppedAt.Format("15:04:05")
+	}
+	return data
 }
 
 func (s *Server) handlePartialSessionDetail(w http.ResponseWriter, r *http.Request) {
@@ -89,30 +516,65 @@ func (s *Server) handlePartialSessionDetail(w http.ResponseWriter, r *http.Reque
 	pendingApprovals := s.approvals.GetBySession(id)
 	approvals := make([]approvalData, 0, len(pendingApprovals))
 	for _, p := range pendingApprovals {
-		approvals = append(approvals, approvalData{
-			ID:        p.ID,
-			ToolName:  p.ToolName,
-			ToolInput: string(p.ToolInput),
-			Prompt:    p.Prompt,
-		})
+		approvals = append(approvals, s.newApprovalData(sess, p))
 	}
 
-	events := s.events.GetBySession(id, 50)
-	eventList := make([]eventData, 0, len(events))
-	for _, e := range events {
-		eventList = append(eventList, eventData{
-			Timestamp: e.Timestamp,
-			EventName: e.EventName,
-			ToolName:  e.ToolName,
-			Detail:    e.Detail,
-			ToolInput: e.ToolInput,
-		})
+	filter := eventFilter{
+		Event: r.URL.Query().Get("event"),
+		Tool:  r.URL.Query().Get("tool"),
+		Q:     r.URL.Query().Get("q"),
+	}
+	feed, err := s.sessionFeed(sess, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	project := s.cfg.Project(sess.Project)
+	meta, _ := s.cfg.Session(id)
+	budgets := s.cfg.CurrentSettings().Budgets
 	data := sessionDetailData{
-		Session:   sess,
-		Approvals: approvals,
-		Events:    eventList,
+		Session: sess,
+		Project: projectGroup{
+			Dir:   sess.Project,
+			Name:  s.cfg.ProjectName(sess.Project),
+			Color: project.Color,
+		},
+		ReviewTools: project.ReviewTools,
+		ContextForms: []contextFormData{
+			{
+				ID:      "session-" + id,
+				Scope:   "session",
+				Action:  "/api/sessions/" + id + "/context",
+				Context: meta.Context,
+			},
+			{
+				ID:         "project-" + id,
+				Scope:      "project",
+				Action:     "/api/projects/context",
+				ProjectDir: sess.Project,
+				Context:    project.Context,
+			},
+		},
+		Approvals:  approvals,
+		Feed:       feed,
+		Filter:     filter,
+		EventNames: feedEventNames,
+		Git:        s.git.Get(id, sess.ProjectDir, sess.StartedAt),
+		Files:      newFileData(s.files.Files(id), sess.Project),
+		Usage:      s.newUsageView(s.usage.Session(id)),
+		Budgets:    newBudgetViews(s.budgets(sess)),
+		BudgetForm: budgetFormData{
+			SessionUSD: formatBudget(budgets.SessionUSD),
+			ProjectUSD: formatBudget(s.cfg.ProjectBudget(sess.Project)),
+			DailyUSD:   formatBudget(budgets.DailyUSD),
+		},
+	}
+	if trip, ok := s.breaker.Tripped(id); ok {
+		data.Breaker = &trip
+	}
+	if previous := s.sessions.Lineage(id); len(previous) > 0 {
+		data.Previous = newSessionBoundary(previous[0])
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -120,3 +582,191 @@ func (s *Server) handlePartialSessionDetail(w http.ResponseWriter, r *http.Reque
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// newApprovalData prepares a pending approval for its card.
+func (s *Server) newApprovalData(sess *session.Session, p *hooks.PendingApproval) approvalData {
+	var expiresAt int64
+	if p.EventName == "Stop" {
+		expiresAt = p.CreatedAt.Add(s.cfg.StopHold(sess.Project)).Unix()
+	}
+	preview, truncated := s.previewPayload(string(p.ToolInput))
+	var payloadSize string
+	if truncated {
+		payloadSize = hooks.FormatBytes(len(p.ToolInput))
+	}
+	// Questions are answered through PermissionRequest; a question held
+	// for PreToolUse review gets the allow, deny and ask card.
+	var questions []hooks.Question
+	if p.EventName == "PermissionRequest" {
+		questions = hooks.ParseQuestions(p.ToolName, p.ToolInput)
+	}
+	return approvalData{
+		ID:          p.ID,
+		EventName:   p.EventName,
+		ExpiresAt:   expiresAt,
+		ToolName:    p.ToolName,
+		ToolInput:   preview,
+		PayloadSize: payloadSize,
+		Unredacted:  p.RawToolInput != nil,
+		Prompt:      p.Prompt,
+		Warning:     p.Warning,
+		Questions:   questions,
+	}
+}
+
+// newEventData prepares an event for the feed.
+func newEventData(e hooks.Event) eventData {
+	item := eventData{
+		ID:           e.ID,
+		Time:         e.Time.Local().Format("15:04:05"),
+		DateTime:     e.Time.Format(time.RFC3339Nano),
+		EventName:    e.EventName,
+		ToolName:     e.ToolName,
+		Detail:       e.Detail,
+		ToolInput:    e.InputText(),
+		ToolResponse: e.ResponseText(),
+		AgentID:      e.AgentID,
+		SessionID:    e.SessionID,
+	}
+	if e.Truncated {
+		item.PayloadSize = hooks.FormatBytes(e.PayloadSize)
+	}
+	if e.Latency > 0 {
+		item.Latency = formatLatency(e.Latency)
+	}
+	return item
+}
+
+// formatLatency renders a duration such as 350ms, 1.2s or 2m5s.
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	case d < time.Minute:
+		return strconv.FormatFloat(d.Seconds(), 'f', 1, 64) + "s"
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// feedPageSize is how many events the session feed loads at a time.
+const feedPageSize = 50
+
+// feedEventNames are the event types offered by the feed's filter.
+var feedEventNames = []string{
+	"UserPromptSubmit", "PreToolUse", "PostToolUse", "PostToolUseFailure",
+	"PermissionRequest", "Notification", "Stop", "SubagentStart", "SubagentStop",
+	"SessionStart", "SessionEnd", "PreCompact", "PermissionMode", "ContextInjected",
+	"FileConflict", "Budget", "CircuitBreaker", "Liveness",
+}
+
+// eventFilter is the feed filter a session view was rendered with.
+type eventFilter struct {
+	Event string
+	Tool  string
+	Q     string
+}
+
+// Query is the filter as a query string for reloading the view, or empty
+// when nothing is filtered.
+func (f eventFilter) Query() string {
+	values := url.Values{}
+	for name, v := range map[string]string{"event": f.Event, "tool": f.Tool, "q": f.Q} {
+		if v != "" {
+			values.Set(name, v)
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+// eventFeed is one page of a session's event feed.
+type eventFeed struct {
+	Groups []eventGroup
+	// MoreURL loads the next, older page; empty on the last page.
+	MoreURL string
+}
+
+// sessionFeed returns a page of the events of a session and of the earlier
+// sessions it continues, filtered by the event, tool, q and cursor query
+// parameters. The after parameter names the session of the event before
+// the page, so a boundary is only shown where the feed moves to an earlier
+// session.
+func (s *Server) sessionFeed(sess *session.Session, values url.Values) (eventFeed, error) {
+	query, err := parseEventQuery(values, feedPageSize)
+	if err != nil {
+		return eventFeed{}, err
+	}
+	lineage := append([]*session.Session{sess}, s.sessions.Lineage(sess.ID)...)
+	subagents := make(map[string]session.Subagent)
+	boundaries := make(map[string]*sessionBoundary, len(lineage))
+	for i, ls := range lineage {
+		query.SessionIDs = append(query.SessionIDs, ls.ID)
+		maps.Copy(subagents, s.sessions.Subagents(ls.ID))
+		if i > 0 {
+			boundaries[ls.ID] = newSessionBoundary(ls)
+		}
+	}
+
+	events, more := s.events.Query(query)
+	eventList := make([]eventData, 0, len(events))
+	for _, e := range events {
+		eventList = append(eventList, newEventData(e))
+	}
+
+	feed := eventFeed{Groups: groupByPrompt(eventList, subagents)}
+	previous := values.Get("after")
+	if previous == "" {
+		previous = sess.ID
+	}
+	live := query.Before == 0 && values.Get("event") == "" && values.Get("tool") == "" && query.Text == ""
+	for i := range feed.Groups {
+		g := &feed.Groups[i]
+		if g.SessionID != previous {
+			g.Boundary = boundaries[g.SessionID]
+		}
+		previous = g.SessionID
+		switch {
+		case !live:
+		case g.Prompt != nil:
+			g.Key = g.Prompt.ID
+		case i == 0 && g.SessionID == sess.ID:
+			// The latest prompt is on an older page, or there is none.
+			g.Key = s.groupKey(sess.ID, 0)
+		}
+	}
+
+	if cursor := nextCursor(events, more); cursor != "" {
+		next := url.Values{}
+		for _, name := range []string{"event", "tool", "q"} {
+			if v := values.Get(name); v != "" {
+				next.Set(name, v)
+			}
+		}
+		next.Set("cursor", cursor)
+		next.Set("after", events[len(events)-1].SessionID)
+		feed.MoreURL = "/partials/session/" + sess.ID + "/events?" + next.Encode()
+	}
+	return feed, nil
+}
+
+// handlePartialSessionEvents renders the next page of a session's event
+// feed, loaded as the feed is scrolled.
+func (s *Server) handlePartialSessionEvents(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.sessions.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	feed, err := s.sessionFeed(sess, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := partialTemplates.ExecuteTemplate(w, "event_page", feed); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
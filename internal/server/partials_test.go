@@ -0,0 +1,32 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aliadnani/claudehaus/internal/config"
+	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/session"
+)
+
+func TestApprovalDataQuestions(t *testing.T) {
+	s := &Server{cfg: &config.Config{}}
+	sess := &session.Session{ID: "s1", Project: "/tmp/p"}
+	input := json.RawMessage(`{"questions":[{"question":"Which database?","options":[{"label":"Postgres"},{"label":"SQLite"}]}]}`)
+
+	tests := []struct {
+		event string
+		want  int
+	}{
+		{"PermissionRequest", 1},
+		// A question held for review is allowed, denied or passed to the
+		// terminal, not answered.
+		{"PreToolUse", 0},
+	}
+	for _, tt := range tests {
+		p := &hooks.PendingApproval{ID: "a1", SessionID: sess.ID, EventName: tt.event, ToolName: hooks.QuestionToolName, ToolInput: input}
+		if got := len(s.newApprovalData(sess, p).Questions); got != tt.want {
+			t.Errorf("%s: got %d questions, want %d", tt.event, got, tt.want)
+		}
+	}
+}
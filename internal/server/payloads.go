@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/aliadnani/claudehaus/internal/hooks"
+)
+
+// payloadLimits returns the preview and maximum payload sizes in bytes.
+func (s *Server) payloadLimits() (preview, max int) {
+	settings := s.cfg.CurrentSettings()
+	preview = settingInt(settings.PayloadPreviewBytes, hooks.DefaultPreviewBytes)
+	max = settingInt(settings.PayloadMaxBytes, hooks.DefaultPayloadBytes)
+	return preview, max
+}
+
+func (s *Server) applyPayloadLimits() {
+	s.events.SetPayloadLimits(s.payloadLimits())
+}
+
+// previewPayload cuts a payload to the preview size for lists and
+// broadcasts.
+func (s *Server) previewPayload(payload string) (string, bool) {
+	preview, _ := s.payloadLimits()
+	return hooks.Truncate(payload, preview)
+}
+
+func (s *Server) handleEventPayload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	event, payload, ok := s.events.Payload(id)
+	if !ok {
+		http.Error(w, "event not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, struct {
+		ID        string `json:"id"`
+		SessionID string `json:"session_id"`
+		hooks.Payload
+	}{event.ID, event.SessionID, payload})
+}
+
+func (s *Server) handleApprovalPayload(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	pending, ok := s.approvals.Get(id)
+	if !ok {
+		http.Error(w, "approval not found", http.StatusNotFound)
+		return
+	}
+	// The unredacted input, if kept, is only served here, to token
+	// holders; the card itself shows the redacted input.
+	toolInput := pending.ToolInput
+	if pending.RawToolInput != nil {
+		toolInput = pending.RawToolInput
+	}
+	writeJSON(w, struct {
+		ID        string `json:"id"`
+		SessionID string `json:"session_id"`
+		hooks.Payload
+	}{pending.ID, pending.SessionID, hooks.Payload{ToolInput: string(toolInput), InputSize: len(toolInput)}})
+}
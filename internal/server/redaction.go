@@ -0,0 +1,38 @@
+package server
+
+import (
+	"log/slog"
+
+	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/redact"
+)
+
+// newRedactor builds the redactor for the current settings. Invalid custom
+// patterns are logged and skipped so that the built-in detectors still
+// apply.
+func (s *Server) newRedactor() *redact.Redactor {
+	settings := s.cfg.CurrentSettings().Redaction
+	if settings.Disabled {
+		return nil
+	}
+	r, err := redact.New(settings.Patterns)
+	if err != nil {
+		slog.Error("invalid redaction pattern, using built-in detectors only", "error", err)
+		r, _ = redact.New(nil)
+	}
+	return r
+}
+
+// redactInput returns a copy of a hook input with secrets masked in the
+// fields that carry free text. Everything derived from hook input, from the
+// event store to webhooks, uses the redacted copy.
+func (s *Server) redactInput(input hooks.HookInput) hooks.HookInput {
+	r := s.redactor.Load()
+	input.ToolInput = r.JSON(input.ToolInput)
+	input.ToolResponse = r.JSON(input.ToolResponse)
+	input.Prompt = r.String(input.Prompt)
+	input.Message = r.String(input.Message)
+	input.Reason = r.String(input.Reason)
+	input.CustomInstructions = r.String(input.CustomInstructions)
+	return input
+}
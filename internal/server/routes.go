@@ -12,13 +12,31 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(claudehaus.StaticFS())))
 
 	mux.HandleFunc("GET /partials/sessions", s.handlePartialSessions)
+	mux.HandleFunc("GET /partials/search", s.authAPIMiddleware(s.handlePartialSearch))
 	mux.HandleFunc("GET /partials/session/{id}", s.handlePartialSessionDetail)
+	mux.HandleFunc("GET /partials/session/{id}/events", s.authAPIMiddleware(s.handlePartialSessionEvents))
 
 	mux.HandleFunc("POST /api/hooks/{event}", s.authAPIMiddleware(s.handleHook))
 	mux.HandleFunc("GET /api/sessions", s.authAPIMiddleware(s.handleListSessions))
 	mux.HandleFunc("GET /api/sessions/{id}", s.authAPIMiddleware(s.handleGetSession))
 	mux.HandleFunc("PATCH /api/sessions/{id}", s.authAPIMiddleware(s.handleUpdateSession))
+	mux.HandleFunc("GET /api/sessions/{id}/prompts", s.authAPIMiddleware(s.handleListPrompts))
+	mux.HandleFunc("GET /api/sessions/{id}/files", s.authAPIMiddleware(s.handleListFiles))
+	mux.HandleFunc("POST /api/sessions/{id}/breaker/reset", s.authAPIMiddleware(s.handleResetBreaker))
+	mux.HandleFunc("PUT /api/sessions/{id}/context", s.authAPIMiddleware(s.handleUpdateSessionContext))
+	mux.HandleFunc("PUT /api/projects/context", s.authAPIMiddleware(s.handleUpdateProjectContext))
+	mux.HandleFunc("GET /api/context/audit", s.authAPIMiddleware(s.handleContextAudit))
+	mux.HandleFunc("GET /api/prompts", s.authAPIMiddleware(s.handleListPrompts))
+	mux.HandleFunc("GET /api/search", s.authAPIMiddleware(s.handleSearch))
+	mux.HandleFunc("GET /api/usage", s.authAPIMiddleware(s.handleUsage))
+	mux.HandleFunc("GET /api/budgets", s.authAPIMiddleware(s.handleListBudgets))
+	mux.HandleFunc("PATCH /api/budgets", s.authAPIMiddleware(s.handleUpdateBudgets))
+	mux.HandleFunc("GET /api/projects", s.authAPIMiddleware(s.handleListProjects))
+	mux.HandleFunc("PATCH /api/projects", s.authAPIMiddleware(s.handleUpdateProject))
+	mux.HandleFunc("GET /api/events", s.authAPIMiddleware(s.handleListEvents))
+	mux.HandleFunc("GET /api/events/{id}/payload", s.authAPIMiddleware(s.handleEventPayload))
 	mux.HandleFunc("POST /api/approvals/{id}", s.authAPIMiddleware(s.handleApproval))
+	mux.HandleFunc("GET /api/approvals/{id}/payload", s.authAPIMiddleware(s.handleApprovalPayload))
 	mux.HandleFunc("GET /api/settings", s.authAPIMiddleware(s.handleGetSettings))
 	mux.HandleFunc("PATCH /api/settings", s.authAPIMiddleware(s.handleUpdateSettings))
 	mux.HandleFunc("POST /api/tokens", s.authAPIMiddleware(s.handleCreateToken))
@@ -27,6 +45,8 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/verify-token", s.handleVerifyToken)
 
 	mux.HandleFunc("GET /ws", s.handleWebSocket)
+	mux.HandleFunc("GET /api/stream", s.authAPIMiddleware(s.handleStream))
+	mux.HandleFunc("GET /api/ws/stats", s.authAPIMiddleware(s.handleWebSocketStats))
 
 	mux.HandleFunc("GET /", s.handleIndex)
 }
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/search"
+)
+
+// Result counts for search.
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// indexEvent adds tool calls, prompts and notifications to the search
+// index as they are recorded. Event content is already redacted.
+func (s *Server) indexEvent(e hooks.Event) {
+	doc := search.Doc{
+		Key:       "event:" + e.ID,
+		SessionID: e.SessionID,
+		EventID:   e.ID,
+		Time:      e.Time,
+	}
+	switch {
+	case e.EventName == "UserPromptSubmit":
+		doc.Kind, doc.Text = search.KindPrompt, e.Detail
+	case e.EventName == "Notification":
+		doc.Kind, doc.Text = search.KindNotification, e.Detail
+	case e.ToolName != "" && (e.EventName == "PreToolUse" || e.EventName == "PostToolUse" || e.EventName == "PermissionRequest"):
+		// The hooks of one tool call, and repeats of the same call, share
+		// a document that points at the latest of them.
+		h := fnv.New64a()
+		h.Write([]byte(e.ToolName + "\x00" + string(e.ToolInput)))
+		doc.Key = "tool:" + e.SessionID + ":" + strconv.FormatUint(h.Sum64(), 36)
+		doc.Kind, doc.Tool = search.KindTool, e.ToolName
+		doc.Text = e.ToolName + "\n" + search.FlattenJSON(string(e.ToolInput))
+	default:
+		return
+	}
+	if sess, ok := s.sessions.Get(e.SessionID); ok {
+		doc.Project = sess.Project
+	}
+	s.search.Add(doc)
+}
+
+// indexTranscript adds assistant text newly written to a session's
+// transcript to the search index.
+func (s *Server) indexTranscript(sessionID, project, transcriptPath string) {
+	if err := s.search.UpdateTranscript(sessionID, project, transcriptPath, s.redactor.Load().String); err != nil {
+		slog.Debug("failed to index transcript", "session_id", sessionID, "path", transcriptPath, "error", err)
+	}
+}
+
+// searchHit is a search hit with the session it belongs to.
+type searchHit struct {
+	search.Hit
+	Nickname    string `json:"session_nickname"`
+	ProjectName string `json:"project_name"`
+}
+
+// searchQuery reads q, session, project, kind and limit.
+func (s *Server) searchQuery(r *http.Request) (search.Query, error) {
+	q := r.URL.Query()
+	query := search.Query{
+		Text:      q.Get("q"),
+		SessionID: q.Get("session"),
+		Project:   q.Get("project"),
+		Kinds:     listParam(q, "kind"),
+		Limit:     defaultSearchLimit,
+	}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, errInvalidLimit
+		}
+		query.Limit = min(n, maxSearchLimit)
+	}
+	return query, nil
+}
+
+func (s *Server) searchHits(query search.Query) []searchHit {
+	hits := s.search.Search(query)
+	result := make([]searchHit, 0, len(hits))
+	for _, h := range hits {
+		hit := searchHit{Hit: h, ProjectName: s.cfg.ProjectName(h.Project)}
+		if sess, ok := s.sessions.Get(h.SessionID); ok {
+			hit.Nickname = sess.Nickname
+		} else if meta, ok := s.cfg.Session(h.SessionID); ok {
+			hit.Nickname = meta.Nickname
+		}
+		result = append(result, hit)
+	}
+	return result
+}
+
+// handleSearch searches tool calls, prompts, notifications and transcript
+// text across sessions, best match first.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query, err := s.searchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]any{"query": query.Text, "hits": s.searchHits(query)})
+}
+
+// snippetPart is a run of a search snippet, Match when it is a query term.
+type snippetPart struct {
+	Text  string
+	Match bool
+}
+
+// highlight splits a snippet into runs that do and do not contain the
+// query's terms.
+func highlight(snippet string, terms []string) []snippetPart {
+	lower := strings.ToLower(snippet)
+	if len(lower) != len(snippet) || len(terms) == 0 {
+		return []snippetPart{{Text: snippet}}
+	}
+	matched := make([]bool, len(snippet))
+	for _, term := range terms {
+		for at := 0; ; {
+			i := strings.Index(lower[at:], term)
+			if i < 0 {
+				break
+			}
+			for j := at + i; j < at+i+len(term); j++ {
+				matched[j] = true
+			}
+			at += i + len(term)
+		}
+	}
+	var parts []snippetPart
+	for i := 0; i < len(snippet); {
+		j := i
+		for j < len(snippet) && matched[j] == matched[i] {
+			j++
+		}
+		parts = append(parts, snippetPart{Text: snippet[i:j], Match: matched[i]})
+		i = j
+	}
+	return parts
+}
+
+type searchHitView struct {
+	SessionID string
+	EventID   string
+	Nickname  string
+	Project   string
+	Kind      string
+	Tool      string
+	Time      string
+	Snippet   []snippetPart
+}
+
+type searchData struct {
+	Query string
+	Hits  []searchHitView
+}
+
+func (s *Server) handlePartialSearch(w http.ResponseWriter, r *http.Request) {
+	query, err := s.searchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data := searchData{Query: strings.TrimSpace(query.Text)}
+	if data.Query != "" {
+		terms := search.Terms(query.Text)
+		for _, h := range s.searchHits(query) {
+			data.Hits = append(data.Hits, searchHitView{
+				SessionID: h.SessionID,
+				EventID:   h.EventID,
+				Nickname:  h.Nickname,
+				Project:   h.ProjectName,
+				Kind:      h.Kind,
+				Tool:      h.Tool,
+				Time:      h.Time.Local().Format("Jan 2 15:04"),
+				Snippet:   highlight(h.Snippet, terms),
+			})
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := partialTemplates.ExecuteTemplate(w, "search_results", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
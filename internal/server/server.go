@@ -1,13 +1,20 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/aliadnani/claudehaus/internal/config"
+	"github.com/aliadnani/claudehaus/internal/gitinfo"
 	"github.com/aliadnani/claudehaus/internal/hooks"
+	"github.com/aliadnani/claudehaus/internal/redact"
+	"github.com/aliadnani/claudehaus/internal/search"
 	"github.com/aliadnani/claudehaus/internal/session"
+	"github.com/aliadnani/claudehaus/internal/usage"
 )
 
 type Server struct {
@@ -15,7 +22,22 @@ type Server struct {
 	sessions  *session.Store
 	approvals *hooks.ApprovalStore
 	events    *hooks.EventStore
-	hub       *Hub
+	prompts   *hooks.PromptStore
+	audit     *hooks.ContextAudit
+	git       *gitinfo.Cache
+	// gitRefresh coalesces the git reads that follow file changes.
+	gitRefresh *gitRefresher
+	files      *session.FileIndex
+	usage      *usage.Tracker
+	search     *search.Index
+	// budgetSteps remembers how far each budget has escalated.
+	budgetSteps *budgetTracker
+	breaker     *session.Breaker
+	// redactor masks secrets in hook input; nil when redaction is off.
+	redactor atomic.Pointer[redact.Redactor]
+	hub      *Hub
+	// fragments batches the HTML pushed to clients as things change.
+	fragments *fragmentQueue
 	templates *Templates
 }
 
@@ -24,20 +46,43 @@ func New(cfg *config.Config) *Server {
 	if err != nil {
 		panic(err)
 	}
-	return &Server{
-		cfg:       cfg,
-		sessions:  session.NewStore(),
-		approvals: hooks.NewApprovalStore(),
-		events:    hooks.NewEventStore(),
-		hub:       NewHub(),
-		templates: templates,
+	s := &Server{
+		cfg:         cfg,
+		sessions:    session.NewStore(),
+		approvals:   hooks.NewApprovalStore(),
+		events:      hooks.NewEventStore(),
+		prompts:     hooks.NewPromptStore(),
+		audit:       hooks.NewContextAudit(),
+		git:         gitinfo.NewCache(gitInfoTTL),
+		files:       session.NewFileIndex(),
+		usage:       usage.NewTracker(),
+		search:      search.NewIndex(search.DefaultMaxDocs),
+		budgetSteps: newBudgetTracker(),
+		breaker:     session.NewBreaker(),
+		hub:         NewHub(cfg.Server.WebSocket),
+		templates:   templates,
 	}
+	s.redactor.Store(s.newRedactor())
+	s.applyPayloadLimits()
+	s.applyEventLimits()
+	s.fragments = newFragmentQueue(s.pushFragments)
+	s.gitRefresh = newGitRefresher(s.refreshGitInfo)
+	s.events.OnAdd(func(e hooks.Event) {
+		s.indexEvent(e)
+		s.queueEvent(e)
+	})
+	return s
 }
 
 func (s *Server) Run() error {
 	mux := http.NewServeMux()
 	s.registerRoutes(mux)
 
+	go s.hub.Run()
+	monitor := session.NewMonitor(s.sessions, s.monitorConfig, s.handleLivenessChange)
+	go monitor.Run(context.Background())
+	go s.pruneSessionMeta()
+
 	tokens := s.cfg.ListTokens()
 	slog.Info("loaded authentication tokens",
 		"config_path", "~/.claudehaus/config.json",
@@ -57,6 +102,94 @@ func (s *Server) Run() error {
 	return http.ListenAndServe(addr, mux)
 }
 
+// gitInfoTTL is how long cached git information is used before it is read
+// again on demand.
+const gitInfoTTL = 30 * time.Second
+
+// sessionMetaMaxAge is how long per-session metadata (nicknames, pinned
+// context) is kept after the session was last seen.
+const sessionMetaMaxAge = 30 * 24 * time.Hour
+
+// fileActivityMaxAge is how long a session's file activity is kept after
+// its last event.
+const fileActivityMaxAge = 24 * time.Hour
+
+func (s *Server) pruneSessionMeta() {
+	for {
+		if n := s.cfg.PruneSessions(sessionMetaMaxAge); n > 0 {
+			slog.Info("pruned stale session metadata", "removed", n)
+		}
+		if n := s.files.Prune(s.keepFileActivity); n > 0 {
+			slog.Info("pruned stale file activity", "sessions", n)
+		}
+		time.Sleep(time.Hour)
+	}
+}
+
+// keepFileActivity reports whether a session's file activity is still
+// worth keeping: it is known and has had events recently.
+func (s *Server) keepFileActivity(sessionID string) bool {
+	sess, ok := s.sessions.Get(sessionID)
+	return ok && time.Since(sess.LastEventAt) < fileActivityMaxAge
+}
+
+func (s *Server) monitorConfig() session.MonitorConfig {
+	settings := s.cfg.CurrentSettings()
+	return session.MonitorConfig{
+		IdleAfter:  settingSeconds(settings.SessionIdleSeconds, config.DefaultSessionIdleSeconds),
+		StaleAfter: settingSeconds(settings.SessionStaleSeconds, config.DefaultSessionStaleSeconds),
+		Interval:   15 * time.Second,
+	}
+}
+
+// settingSeconds converts a seconds setting to a duration: zero means the
+// default and a negative value disables the check.
+func settingSeconds(value, def int) time.Duration {
+	switch {
+	case value < 0:
+		return 0
+	case value == 0:
+		return time.Duration(def) * time.Second
+	default:
+		return time.Duration(value) * time.Second
+	}
+}
+
+// settingInt resolves a numeric setting where zero means the default and a
+// negative value disables the check, reported as -1.
+func settingInt(value, def int) int {
+	switch {
+	case value < 0:
+		return -1
+	case value == 0:
+		return def
+	default:
+		return value
+	}
+}
+
+func (s *Server) handleLivenessChange(t session.Transition) {
+	slog.Info("session liveness changed",
+		"session_id", t.SessionID,
+		"from", t.From,
+		"to", t.To,
+		"reason", t.Reason)
+	s.events.AddEvent(t.SessionID, "Liveness", "Marked "+string(t.To)+": "+t.Reason)
+	s.hub.Broadcast(Message{Type: "session_update", SessionID: t.SessionID, Data: map[string]any{"status": string(t.To)}})
+	if t.To == session.StatusEnded {
+		s.forgetSession(t.SessionID)
+	}
+}
+
+// forgetSession drops the state only needed while a session runs: the
+// usage messages it may still update, its breaker and how far its budget
+// escalated.
+func (s *Server) forgetSession(sessionID string) {
+	s.usage.Forget(sessionID)
+	s.breaker.Forget(sessionID)
+	s.budgetSteps.forget(sessionID)
+}
+
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte("OK"))
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// streamKeepalive is how often an idle event stream gets a comment line,
+// so proxies keep it open and clients can tell it is alive.
+const streamKeepalive = 30 * time.Second
+
+// streamFilter limits the messages a client receives. Empty lists match
+// everything; resync notices always match.
+type streamFilter struct {
+	sessions []string
+	types    []string
+}
+
+func (f streamFilter) match(m *hubMessage) bool {
+	if m == resyncMessage {
+		return true
+	}
+	if len(f.sessions) > 0 && !slices.Contains(f.sessions, m.sessionID) {
+		return false
+	}
+	return len(f.types) == 0 || slices.Contains(f.types, m.typ)
+}
+
+// handleStream serves the hub's messages as Server-Sent Events, for
+// clients and proxies that handle WebSockets badly. session and type
+// filter the stream; Last-Event-ID, or last_event_id, resumes it.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	client := s.hub.newClient("sse", r)
+	client.filter = streamFilter{
+		sessions: listParam(q, "session"),
+		types:    listParam(q, "type"),
+	}
+	client.lastEventID = r.Header.Get("Last-Event-ID")
+	if client.lastEventID == "" {
+		client.lastEventID = q.Get("last_event_id")
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	s.hub.register <- client
+	defer func() { s.hub.unregister <- client }()
+
+	ticker := time.NewTicker(streamKeepalive)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case m, ok := <-client.send:
+			if !ok {
+				// The hub dropped a client that fell behind.
+				return
+			}
+			_ = rc.SetWriteDeadline(time.Now().Add(writeWait))
+			if m.id != 0 {
+				_, err = fmt.Fprintf(w, "id: %s\n", s.hub.eventID(m))
+			}
+			if err == nil {
+				_, err = fmt.Fprintf(w, "data: %s\n\n", m.data)
+			}
+		case <-ticker.C:
+			_ = rc.SetWriteDeadline(time.Now().Add(writeWait))
+			_, err = io.WriteString(w, ": keepalive\n\n")
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
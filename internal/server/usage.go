@@ -0,0 +1,101 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/usage"
+)
+
+// usageSummary is token usage with its cost under the current price table.
+type usageSummary struct {
+	Models map[string]usage.Tokens `json:"models"`
+	Tokens usage.Tokens            `json:"tokens"`
+	Cost   float64                 `json:"cost_usd"`
+}
+
+func (s *Server) priceTable() usage.PriceTable {
+	return usage.DefaultPrices.WithOverrides(s.cfg.CurrentSettings().Prices)
+}
+
+func (s *Server) summarizeUsage(byModel map[string]usage.Tokens) usageSummary {
+	summary := usageSummary{Models: byModel, Cost: s.priceTable().Cost(byModel)}
+	for _, tokens := range byModel {
+		summary.Tokens.Add(tokens)
+	}
+	return summary
+}
+
+// updateUsage reads new usage from a session's transcript, escalates
+// budgets it crossed and updates the UI if there was any. See
+// usage.Tracker.Update for since.
+func (s *Server) updateUsage(sessionID, project, transcriptPath string, since time.Time) {
+	changed, err := s.usage.Update(sessionID, project, transcriptPath, since)
+	if err != nil {
+		slog.Debug("failed to read transcript usage", "session_id", sessionID, "path", transcriptPath, "error", err)
+	}
+	if !changed {
+		return
+	}
+	if sess, ok := s.sessions.Get(sessionID); ok {
+		s.checkBudgets(sess)
+	}
+	s.hub.Broadcast(Message{Type: "usage_update", SessionID: sessionID})
+	s.queueUsage(sessionID)
+}
+
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid days", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	project := r.URL.Query().Get("project")
+	since := time.Now().AddDate(0, 0, -(days - 1)).Format(time.DateOnly)
+
+	type dayUsage struct {
+		Date    string `json:"date"`
+		Project string `json:"project"`
+		usageSummary
+	}
+	daily := make([]dayUsage, 0)
+	projects := make(map[string]usageSummary)
+	for _, d := range s.usage.Daily(since, project) {
+		daily = append(daily, dayUsage{Date: d.Date, Project: d.Project, usageSummary: s.summarizeUsage(d.Models)})
+		if _, ok := projects[d.Project]; !ok {
+			projects[d.Project] = s.summarizeUsage(s.usage.Project(d.Project))
+		}
+	}
+
+	writeJSON(w, map[string]any{
+		"daily":    daily,
+		"projects": projects,
+	})
+}
+
+// formatCost renders a dollar amount for the UI.
+func formatCost(cost float64) string {
+	if cost < 0.01 && cost > 0 {
+		return "<$0.01"
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
+// formatTokens renders a token count compactly, e.g. 12.3k or 1.2M.
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return strconv.FormatInt(n, 10)
+	}
+}
@@ -4,28 +4,117 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/aliadnani/claudehaus/internal/config"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+const (
+	// writeWait is how long a write to a client may take.
+	writeWait = 10 * time.Second
+	// pongWait is how long a client may stay silent, pongs included,
+	// before its connection is treated as dead.
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait so a live client always
+	// answers in time.
+	pingPeriod = pongWait * 9 / 10
+	// maxClientMessage bounds what a client may send; the UI only sends
+	// control frames.
+	maxClientMessage = 4096
+	// broadcastQueueSize is how many messages may wait for the hub's run
+	// loop before Broadcast starts dropping them.
+	broadcastQueueSize = 1024
+	// resyncRetry is how often clients that dropped messages are told to
+	// resync when no new message comes along to carry the notice.
+	resyncRetry = time.Second
+	// historySize is how many recent messages are kept for streams that
+	// resume with Last-Event-ID.
+	historySize = 1000
+)
+
+// Slow client policies, for when a client's queue is full.
+const (
+	SlowClientDisconnect = "disconnect"
+	SlowClientDrop       = "drop"
+)
+
+// resyncMessage tells a client that missed messages to reload its views.
+var resyncMessage = &hubMessage{typ: "resync", data: []byte(`{"type":"resync"}`)}
+
+// hubMessage is a marshaled Message on its way to clients. id counts up
+// from 1 as the run loop takes messages; resync notices have none.
+type hubMessage struct {
+	id        uint64
+	typ       string
+	sessionID string
+	data      []byte
 }
 
+// Hub fans broadcast messages out to connected clients, over WebSocket or
+// Server-Sent Events. A single run loop owns delivery, so Broadcast never
+// waits on a client and a slow or dead connection only ever holds up
+// itself.
 type Hub struct {
+	// mu guards clients. Only the run loop changes it.
 	mu      sync.RWMutex
 	clients map[*Client]bool
+
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan *hubMessage
+
+	queueSize  int
+	slowClient string
+
+	// boot tells this process's message IDs from a previous one's, so a
+	// stream resuming across a restart knows it missed messages. seq and
+	// history are the run loop's.
+	boot    string
+	seq     uint64
+	history []*hubMessage
+
+	stats hubStats
+}
+
+type hubStats struct {
+	// broadcasts is messages handed to the run loop; broadcastsDropped
+	// those that didn't fit in its queue.
+	broadcasts        atomic.Uint64
+	broadcastsDropped atomic.Uint64
+	// queued is messages placed on client queues; dropped those skipped
+	// because a queue was full.
+	queued  atomic.Uint64
+	dropped atomic.Uint64
+	// connects counts every client, disconnects the ones that left and
+	// slowDisconnects those closed for falling behind.
+	connects        atomic.Uint64
+	disconnects     atomic.Uint64
+	slowDisconnects atomic.Uint64
 }
 
 type Client struct {
-	hub        *Hub
-	conn       *websocket.Conn
-	send       chan []byte
-	remoteAddr string
-	userAgent  string
+	hub *Hub
+	// conn is nil for event streams, which are written by their handler.
+	conn        *websocket.Conn
+	transport   string
+	send        chan *hubMessage
+	filter      streamFilter
+	remoteAddr  string
+	userAgent   string
+	connectedAt time.Time
+	// lastEventID is where a resumed stream left off; messages after it
+	// are replayed when the client registers.
+	lastEventID string
+	// dropped counts messages skipped for this client. lagging is set
+	// from the first skipped message until the client is told to resync;
+	// only the run loop touches it.
+	dropped atomic.Uint64
+	lagging bool
 }
 
 type Message struct {
@@ -34,73 +123,293 @@ type Message struct {
 	Data      any    `json:"data,omitempty"`
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		clients: make(map[*Client]bool),
+func NewHub(cfg config.WebSocketConfig) *Hub {
+	h := &Hub{
+		clients:    make(map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan *hubMessage, broadcastQueueSize),
+		queueSize:  cfg.QueueSize,
+		slowClient: cfg.SlowClient,
+		boot:       strconv.FormatInt(time.Now().UnixMilli(), 36),
+	}
+	if h.queueSize <= 0 {
+		h.queueSize = config.DefaultWebSocketQueueSize
+	}
+	if h.slowClient != SlowClientDrop {
+		if h.slowClient != "" && h.slowClient != SlowClientDisconnect {
+			slog.Warn("unknown websocket slow_client policy, disconnecting slow clients", "slow_client", h.slowClient)
+		}
+		h.slowClient = SlowClientDisconnect
 	}
+	return h
 }
 
-func (h *Hub) Register(c *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.clients[c] = true
-	slog.Info("websocket client connected",
-		"remote_addr", c.remoteAddr,
-		"user_agent", c.userAgent,
-		"total_clients", len(h.clients))
+// newClient makes a client for a request, with a queue of the configured
+// size.
+func (h *Hub) newClient(transport string, r *http.Request) *Client {
+	return &Client{
+		hub:         h,
+		transport:   transport,
+		send:        make(chan *hubMessage, h.queueSize),
+		remoteAddr:  r.RemoteAddr,
+		userAgent:   r.UserAgent(),
+		connectedAt: time.Now(),
+	}
+}
+
+// Run delivers messages to clients until the process exits.
+func (h *Hub) Run() {
+	ticker := time.NewTicker(resyncRetry)
+	defer ticker.Stop()
+	for {
+		select {
+		case c := <-h.register:
+			h.mu.Lock()
+			h.clients[c] = true
+			total := len(h.clients)
+			h.mu.Unlock()
+			h.stats.connects.Add(1)
+			slog.Info("live client connected",
+				"transport", c.transport,
+				"remote_addr", c.remoteAddr,
+				"user_agent", c.userAgent,
+				"total_clients", total)
+			if c.lastEventID != "" {
+				h.replay(c)
+			}
+		case c := <-h.unregister:
+			h.remove(c, "closed")
+		case m := <-h.broadcast:
+			h.seq++
+			m.id = h.seq
+			h.history = append(h.history, m)
+			if len(h.history) > historySize {
+				h.history = h.history[1:]
+			}
+			for c := range h.clients {
+				h.deliver(c, m)
+			}
+		case <-ticker.C:
+			for c := range h.clients {
+				h.resync(c)
+			}
+		}
+	}
+}
+
+// deliver queues a message for a client without waiting. A full queue
+// either drops the message or the client, depending on the policy. It
+// reports whether the client is still connected; a removed client's queue
+// is closed and must not be sent to again.
+func (h *Hub) deliver(c *Client, m *hubMessage) bool {
+	if !c.filter.match(m) {
+		return true
+	}
+	h.resync(c)
+	if !c.lagging {
+		select {
+		case c.send <- m:
+			h.stats.queued.Add(1)
+			return true
+		default:
+		}
+	}
+	if h.slowClient == SlowClientDrop {
+		if !c.lagging {
+			slog.Warn("live client falling behind, dropping messages", "transport", c.transport, "remote_addr", c.remoteAddr)
+		}
+		c.lagging = true
+		c.dropped.Add(1)
+		h.stats.dropped.Add(1)
+		return true
+	}
+	h.stats.slowDisconnects.Add(1)
+	h.remove(c, "queue full")
+	return false
+}
+
+// resync tells a client that dropped messages to reload, once its queue
+// has room.
+func (h *Hub) resync(c *Client) {
+	if !c.lagging {
+		return
+	}
+	select {
+	case c.send <- resyncMessage:
+		c.lagging = false
+	default:
+	}
 }
 
-func (h *Hub) Unregister(c *Client) {
+// eventID formats a message ID for an event stream.
+func (h *Hub) eventID(m *hubMessage) string {
+	return h.boot + "-" + strconv.FormatUint(m.id, 10)
+}
+
+// replay queues the history after a resumed stream's last event ID. If
+// some of what it missed is gone, from this process or before a restart,
+// it is told to resync first.
+func (h *Hub) replay(c *Client) {
+	boot, seq, _ := strings.Cut(c.lastEventID, "-")
+	after, err := strconv.ParseUint(seq, 10, 64)
+	missed := boot != h.boot || err != nil || after > h.seq
+	if missed {
+		after = 0
+	}
+	if len(h.history) > 0 && after+1 < h.history[0].id {
+		missed = true
+	}
+	if missed {
+		c.lagging = true
+		h.resync(c)
+	}
+	for _, m := range h.history {
+		if m.id > after && !h.deliver(c, m) {
+			return
+		}
+	}
+}
+
+// remove forgets a client and closes its queue, which makes its write pump
+// close the connection.
+func (h *Hub) remove(c *Client, reason string) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	if _, ok := h.clients[c]; ok {
+	_, ok := h.clients[c]
+	if ok {
 		delete(h.clients, c)
 		close(c.send)
-		slog.Info("websocket client disconnected",
-			"remote_addr", c.remoteAddr,
-			"total_clients", len(h.clients))
 	}
+	total := len(h.clients)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+	h.stats.disconnects.Add(1)
+	slog.Info("live client disconnected",
+		"transport", c.transport,
+		"remote_addr", c.remoteAddr,
+		"reason", reason,
+		"dropped", c.dropped.Load(),
+		"total_clients", total)
 }
 
+// Broadcast hands a message to the run loop. It never blocks: if the loop
+// is that far behind, the message is dropped and counted.
 func (h *Hub) Broadcast(msg Message) {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		slog.Error("failed to marshal message", "error", err)
 		return
 	}
+	select {
+	case h.broadcast <- &hubMessage{typ: msg.Type, sessionID: msg.SessionID, data: data}:
+		h.stats.broadcasts.Add(1)
+	default:
+		if h.stats.broadcastsDropped.Add(1) == 1 {
+			slog.Warn("broadcast queue full, dropping messages")
+		}
+	}
+}
+
+// HubStats reports the hub's configuration, counters and clients.
+type HubStats struct {
+	QueueSize         int           `json:"queue_size"`
+	SlowClient        string        `json:"slow_client"`
+	Broadcasts        uint64        `json:"broadcasts"`
+	BroadcastsDropped uint64        `json:"broadcasts_dropped"`
+	Queued            uint64        `json:"queued"`
+	Dropped           uint64        `json:"dropped"`
+	Connects          uint64        `json:"connects"`
+	Disconnects       uint64        `json:"disconnects"`
+	SlowDisconnects   uint64        `json:"slow_disconnects"`
+	Clients           []ClientStats `json:"clients"`
+}
+
+type ClientStats struct {
+	// Transport is "websocket" or "sse".
+	Transport   string    `json:"transport"`
+	RemoteAddr  string    `json:"remote_addr"`
+	UserAgent   string    `json:"user_agent"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// Queued is how many messages are waiting to be written.
+	Queued  int    `json:"queued"`
+	Dropped uint64 `json:"dropped"`
+}
 
+func (h *Hub) Stats() HubStats {
+	stats := HubStats{
+		QueueSize:         h.queueSize,
+		SlowClient:        h.slowClient,
+		Broadcasts:        h.stats.broadcasts.Load(),
+		BroadcastsDropped: h.stats.broadcastsDropped.Load(),
+		Queued:            h.stats.queued.Load(),
+		Dropped:           h.stats.dropped.Load(),
+		Connects:          h.stats.connects.Load(),
+		Disconnects:       h.stats.disconnects.Load(),
+		SlowDisconnects:   h.stats.slowDisconnects.Load(),
+		Clients:           []ClientStats{},
+	}
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-
-	for client := range h.clients {
-		select {
-		case client.send <- data:
-		default:
-			go h.Unregister(client)
-		}
+	for c := range h.clients {
+		stats.Clients = append(stats.Clients, ClientStats{
+			Transport:   c.transport,
+			RemoteAddr:  c.remoteAddr,
+			UserAgent:   c.userAgent,
+			ConnectedAt: c.connectedAt,
+			Queued:      len(c.send),
+			Dropped:     c.dropped.Load(),
+		})
 	}
+	return stats
 }
 
+// readPump discards what the client sends and keeps the read deadline
+// moving while pongs arrive, so a half-open connection times out.
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.Unregister(c)
+		c.hub.unregister <- c
 		_ = c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxClientMessage)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
 	for {
-		_, _, err := c.conn.ReadMessage()
-		if err != nil {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
 			break
 		}
 	}
 }
 
+// writePump writes queued messages and pings the client every pingPeriod.
+// It closes the connection when the hub closes the queue.
 func (c *Client) writePump() {
-	defer func() { _ = c.conn.Close() }()
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
 
-	for message := range c.send {
-		if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-			return
+	for {
+		select {
+		case message, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "queue full"))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message.data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
 		}
 	}
 }
@@ -113,22 +422,28 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+		EnableCompression: s.cfg.Server.WebSocket.Compression,
+	}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		slog.Error("websocket upgrade failed", "error", err, "remote_addr", r.RemoteAddr)
 		return
 	}
 
-	client := &Client{
-		hub:        s.hub,
-		conn:       conn,
-		send:       make(chan []byte, 256),
-		remoteAddr: r.RemoteAddr,
-		userAgent:  r.UserAgent(),
-	}
-
-	s.hub.Register(client)
+	client := s.hub.newClient("websocket", r)
+	client.conn = conn
+	s.hub.register <- client
 
 	go client.writePump()
 	client.readPump()
 }
+
+// handleWebSocketStats reports live connections, WebSocket and event
+// stream alike, and delivery counters.
+func (s *Server) handleWebSocketStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.hub.Stats())
+}
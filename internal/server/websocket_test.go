@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/aliadnani/claudehaus/internal/config"
+)
+
+// newResumingClient returns a hub holding more history than a client queue
+// and a registered client resuming from its first message.
+func newResumingClient(t *testing.T, slowClient string) (*Hub, *Client) {
+	t.Helper()
+	h := NewHub(config.WebSocketConfig{QueueSize: 4, SlowClient: slowClient})
+	for i := 0; i < 20; i++ {
+		h.seq++
+		h.history = append(h.history, &hubMessage{id: h.seq, typ: "event", data: []byte(`{"type":"event"}`)})
+	}
+	c := h.newClient("sse", httptest.NewRequest("GET", "/api/stream", nil))
+	c.lastEventID = h.boot + "-" + strconv.FormatUint(h.history[0].id, 10)
+	h.clients[c] = true
+	return h, c
+}
+
+func TestReplayBacklogLargerThanQueue(t *testing.T) {
+	t.Run("disconnect", func(t *testing.T) {
+		h, c := newResumingClient(t, SlowClientDisconnect)
+		h.replay(c)
+
+		if h.clients[c] {
+			t.Fatal("client still registered after its queue overflowed")
+		}
+		n := 0
+		for range c.send {
+			n++
+		}
+		if n != h.queueSize {
+			t.Fatalf("queued %d messages before disconnecting, want %d", n, h.queueSize)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		h, c := newResumingClient(t, SlowClientDrop)
+		h.replay(c)
+
+		if !h.clients[c] {
+			t.Fatal("client removed under the drop policy")
+		}
+		if !c.lagging {
+			t.Fatal("client not told to resync after dropping messages")
+		}
+		if got, want := c.dropped.Load(), uint64(len(h.history)-1-h.queueSize); got != want {
+			t.Fatalf("dropped %d messages, want %d", got, want)
+		}
+	})
+}
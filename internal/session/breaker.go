@@ -0,0 +1,138 @@
+package session
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxBreakerCalls bounds the tool calls remembered per session.
+const maxBreakerCalls = 1000
+
+// BreakerLimits are the thresholds at which a session's breaker trips. A
+// zero or negative limit disables that check.
+type BreakerLimits struct {
+	// Repeats is how many calls with the same fingerprint within Window
+	// count as a loop.
+	Repeats int
+	Window  time.Duration
+	// PerMinute is the most tool calls allowed in any minute.
+	PerMinute int
+}
+
+// Trip records why a session's breaker tripped.
+type Trip struct {
+	Reason string    `json:"reason"`
+	Tool   string    `json:"tool"`
+	At     time.Time `json:"at"`
+}
+
+type breakerCall struct {
+	toolUseID   string
+	fingerprint string
+	at          time.Time
+}
+
+type breakerState struct {
+	calls []breakerCall
+	trip  *Trip
+}
+
+// Breaker watches each session's tool calls for runaway loops (the same
+// call repeated) and excessive call rates. Once tripped, a session stays
+// tripped until Reset.
+type Breaker struct {
+	mu       sync.Mutex
+	sessions map[string]*breakerState
+}
+
+func NewBreaker() *Breaker {
+	return &Breaker{
+		sessions: make(map[string]*breakerState),
+	}
+}
+
+// Record notes a tool call by a session. Calls already recorded under the
+// same toolUseID are ignored, so PreToolUse and PostToolUse can both be
+// reported. It returns the trip when this call tripped the breaker.
+func (b *Breaker) Record(sessionID, toolUseID, tool, fingerprint string, limits BreakerLimits) (Trip, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.sessions[sessionID]
+	if !ok {
+		state = &breakerState{}
+		b.sessions[sessionID] = state
+	}
+	if toolUseID != "" && slices.ContainsFunc(state.calls, func(c breakerCall) bool { return c.toolUseID == toolUseID }) {
+		return Trip{}, false
+	}
+
+	now := time.Now()
+	keep := max(limits.Window, time.Minute)
+	state.calls = slices.DeleteFunc(state.calls, func(c breakerCall) bool { return now.Sub(c.at) > keep })
+	state.calls = append(state.calls, breakerCall{toolUseID: toolUseID, fingerprint: fingerprint, at: now})
+	if len(state.calls) > maxBreakerCalls {
+		state.calls = slices.Delete(state.calls, 0, len(state.calls)-maxBreakerCalls)
+	}
+	if state.trip != nil {
+		return Trip{}, false
+	}
+
+	repeats, lastMinute := 0, 0
+	for _, c := range state.calls {
+		if c.fingerprint == fingerprint && now.Sub(c.at) <= limits.Window {
+			repeats++
+		}
+		if now.Sub(c.at) <= time.Minute {
+			lastMinute++
+		}
+	}
+
+	var reason string
+	switch {
+	case limits.Repeats > 0 && limits.Window > 0 && repeats >= limits.Repeats:
+		reason = fmt.Sprintf("%s called %d times with the same input in %s", tool, repeats, limits.Window)
+	case limits.PerMinute > 0 && lastMinute > limits.PerMinute:
+		reason = fmt.Sprintf("%d tool calls in the last minute", lastMinute)
+	default:
+		return Trip{}, false
+	}
+	state.trip = &Trip{Reason: reason, Tool: tool, At: now}
+	return *state.trip, true
+}
+
+// Tripped returns why a session's breaker is tripped, if it is.
+func (b *Breaker) Tripped(sessionID string) (Trip, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.sessions[sessionID]
+	if !ok || state.trip == nil {
+		return Trip{}, false
+	}
+	return *state.trip, true
+}
+
+// Reset closes a session's breaker and forgets its recent calls, so the
+// calls that tripped it do not trip it again. It reports whether the
+// breaker was tripped.
+func (b *Breaker) Reset(sessionID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.sessions[sessionID]
+	if !ok || state.trip == nil {
+		return false
+	}
+	state.trip = nil
+	state.calls = nil
+	return true
+}
+
+// Forget drops everything recorded for a session that has ended: its
+// recent calls, the tool use IDs seen and any trip.
+func (b *Breaker) Forget(sessionID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.sessions, sessionID)
+}
@@ -0,0 +1,182 @@
+package session
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxFileChanges bounds the tool calls remembered per session and file.
+const maxFileChanges = 50
+
+// FileAccess is one tool call that read or modified a file.
+type FileAccess struct {
+	// ToolUseID links the PreToolUse and PostToolUse events of one call,
+	// so the call is only counted once.
+	ToolUseID string   `json:"tool_use_id,omitempty"`
+	EventIDs  []string `json:"event_ids"`
+	Tool      string   `json:"tool"`
+	Write     bool     `json:"write"`
+	// Completed is set once PostToolUse reported the call.
+	Completed bool      `json:"completed"`
+	At        time.Time `json:"at"`
+	// Diff is the change made, as "-"/"+" lines, for modifying calls.
+	Diff string `json:"diff,omitempty"`
+}
+
+// FileTouch summarises one session's access to one file.
+type FileTouch struct {
+	Reads   int  `json:"reads"`
+	Writes  int  `json:"writes"`
+	Created bool `json:"created"`
+	// LastRead and LastWrite are when completed calls read or modified the
+	// file; LastTouched also includes calls that have not completed.
+	LastRead    time.Time    `json:"last_read,omitzero"`
+	LastWrite   time.Time    `json:"last_write,omitzero"`
+	LastTouched time.Time    `json:"last_touched"`
+	Accesses    []FileAccess `json:"accesses"`
+}
+
+// FileRecord is a file touched by a session.
+type FileRecord struct {
+	Path string `json:"path"`
+	FileTouch
+}
+
+// FileWriter is another session that modified a file.
+type FileWriter struct {
+	SessionID string
+	At        time.Time
+}
+
+// FileIndex records which sessions read and modified which files, so edits
+// by one session to files recently changed by another can be flagged.
+type FileIndex struct {
+	mu    sync.RWMutex
+	files map[string]map[string]*FileTouch // path -> session ID
+}
+
+func NewFileIndex() *FileIndex {
+	return &FileIndex{
+		files: make(map[string]map[string]*FileTouch),
+	}
+}
+
+// Record notes a tool call by sessionID that read or modified path. A call
+// already recorded under the same ToolUseID is updated rather than counted
+// again. created marks the file as created by the session.
+func (x *FileIndex) Record(sessionID, path string, access FileAccess, created bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	sessions, ok := x.files[path]
+	if !ok {
+		sessions = make(map[string]*FileTouch)
+		x.files[path] = sessions
+	}
+	touch, ok := sessions[sessionID]
+	if !ok {
+		touch = &FileTouch{}
+		sessions[sessionID] = touch
+	}
+
+	now := time.Now()
+	touch.LastTouched = now
+	touch.Created = touch.Created || created
+	if access.Completed {
+		if access.Write {
+			touch.LastWrite = now
+		} else {
+			touch.LastRead = now
+		}
+	}
+
+	if access.ToolUseID != "" {
+		for i := range touch.Accesses {
+			prev := &touch.Accesses[i]
+			if prev.ToolUseID == access.ToolUseID {
+				prev.EventIDs = append(prev.EventIDs, access.EventIDs...)
+				prev.Completed = prev.Completed || access.Completed
+				if prev.Diff == "" {
+					prev.Diff = access.Diff
+				}
+				return
+			}
+		}
+	}
+
+	if access.Write {
+		touch.Writes++
+	} else {
+		touch.Reads++
+	}
+	access.At = now
+	touch.Accesses = append(touch.Accesses, access)
+	if len(touch.Accesses) > maxFileChanges {
+		touch.Accesses = slices.Delete(touch.Accesses, 0, len(touch.Accesses)-maxFileChanges)
+	}
+}
+
+// Files returns the files touched by a session, most recently touched
+// first.
+func (x *FileIndex) Files(sessionID string) []FileRecord {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	result := make([]FileRecord, 0)
+	for path, sessions := range x.files {
+		touch, ok := sessions[sessionID]
+		if !ok {
+			continue
+		}
+		record := FileRecord{Path: path, FileTouch: *touch}
+		record.Accesses = slices.Clone(touch.Accesses)
+		result = append(result, record)
+	}
+	slices.SortFunc(result, func(a, b FileRecord) int {
+		return b.LastTouched.Compare(a.LastTouched)
+	})
+	return result
+}
+
+// ConflictingWriters returns the sessions other than sessionID that
+// modified path after since and after sessionID last modified it, so
+// repeated edits by the same pair of sessions are only reported once.
+func (x *FileIndex) ConflictingWriters(sessionID, path string, since time.Time) []FileWriter {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	sessions := x.files[path]
+	if own, ok := sessions[sessionID]; ok && own.LastWrite.After(since) {
+		since = own.LastWrite
+	}
+
+	var writers []FileWriter
+	for id, touch := range sessions {
+		if id != sessionID && touch.LastWrite.After(since) {
+			writers = append(writers, FileWriter{SessionID: id, At: touch.LastWrite})
+		}
+	}
+	return writers
+}
+
+// Prune drops the file activity of sessions keep rejects and reports how
+// many sessions it dropped.
+func (x *FileIndex) Prune(keep func(sessionID string) bool) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	dropped := make(map[string]bool)
+	for path, sessions := range x.files {
+		for id := range sessions {
+			if dropped[id] || !keep(id) {
+				dropped[id] = true
+				delete(sessions, id)
+			}
+		}
+		if len(sessions) == 0 {
+			delete(x.files, path)
+		}
+	}
+	return len(dropped)
+}
@@ -0,0 +1,23 @@
+package session
+
+import "testing"
+
+func TestFileIndexPrune(t *testing.T) {
+	x := NewFileIndex()
+	x.Record("live", "/p/a.go", FileAccess{Tool: "Edit", Write: true, Completed: true}, false)
+	x.Record("gone", "/p/a.go", FileAccess{Tool: "Read", Completed: true}, false)
+	x.Record("gone", "/p/b.go", FileAccess{Tool: "Edit", Write: true, Completed: true}, false)
+
+	if n := x.Prune(func(id string) bool { return id == "live" }); n != 1 {
+		t.Fatalf("pruned %d sessions, want 1", n)
+	}
+	if files := x.Files("gone"); len(files) != 0 {
+		t.Errorf("pruned session still has %d files", len(files))
+	}
+	if files := x.Files("live"); len(files) != 1 {
+		t.Errorf("kept session has %d files, want 1", len(files))
+	}
+	if _, ok := x.files["/p/b.go"]; ok {
+		t.Error("file touched only by the pruned session is still indexed")
+	}
+}
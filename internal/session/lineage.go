@@ -0,0 +1,98 @@
+package session
+
+// Session start sources reported with SessionStart.
+const (
+	SourceStartup = "startup"
+	SourceResume  = "resume"
+	SourceClear   = "clear"
+	SourceCompact = "compact"
+)
+
+// maxLineage bounds how far back a chain of linked sessions is followed.
+const maxLineage = 10
+
+// RecordStart records the source and transcript of a SessionStart.
+func (s *Store) RecordStart(id, source, transcriptPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[id]; ok {
+		sess.Source = source
+		if transcriptPath != "" {
+			sess.TranscriptPath = transcriptPath
+		}
+	}
+}
+
+// LinkPredecessor links a session to the session it continues and returns
+// the predecessor. An earlier session with the same transcript is preferred;
+// otherwise the most recently active earlier session in the same project
+// that is no longer active and that no other session continues yet. A
+// session still running in another terminal is never taken as the
+// predecessor by project alone.
+func (s *Store) LinkPredecessor(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok || sess.PreviousID != "" {
+		return nil, false
+	}
+
+	continued := make(map[string]bool)
+	for _, other := range s.sessions {
+		if other.PreviousID != "" {
+			continued[other.PreviousID] = true
+		}
+	}
+	candidate := func(other *Session) bool {
+		return other.ID != id && !continued[other.ID] && other.StartedAt.Before(sess.StartedAt)
+	}
+
+	var best *Session
+	if sess.TranscriptPath != "" {
+		for _, other := range s.sessions {
+			if candidate(other) && other.TranscriptPath == sess.TranscriptPath {
+				best = other
+				break
+			}
+		}
+	}
+	if best == nil {
+		for _, other := range s.sessions {
+			if !candidate(other) || other.Project != sess.Project || other.Status == StatusActive {
+				continue
+			}
+			if best == nil || other.LastEventAt.After(best.LastEventAt) {
+				best = other
+			}
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+
+	sess.PreviousID = best.ID
+	return best, true
+}
+
+// Lineage returns the sessions that id continues, nearest first, not
+// including id itself.
+func (s *Store) Lineage(id string) []*Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*Session, 0)
+	seen := map[string]bool{id: true}
+	sess, ok := s.sessions[id]
+	for ok && sess.PreviousID != "" && len(result) < maxLineage {
+		if seen[sess.PreviousID] {
+			break
+		}
+		seen[sess.PreviousID] = true
+		sess, ok = s.sessions[sess.PreviousID]
+		if ok {
+			result = append(result, sess)
+		}
+	}
+	return result
+}
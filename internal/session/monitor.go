@@ -0,0 +1,103 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// MonitorConfig controls how quickly quiet sessions change status.
+type MonitorConfig struct {
+	// IdleAfter marks an active session idle after this long without events.
+	IdleAfter time.Duration
+	// StaleAfter marks an active or idle session stale after this long
+	// without events.
+	StaleAfter time.Duration
+	// Interval is how often sessions are checked.
+	Interval time.Duration
+}
+
+// Transition is a status change made by the monitor.
+type Transition struct {
+	SessionID string
+	From      Status
+	To        Status
+	Reason    string
+}
+
+// Monitor watches session liveness. Sessions that stop sending events go
+// idle and then stale, and sessions whose Claude Code process has exited are
+// marked ended. Sessions with pending approvals are left alone, since their
+// hook is still blocked waiting on a decision.
+type Monitor struct {
+	store    *Store
+	config   func() MonitorConfig
+	onChange func(Transition)
+}
+
+// NewMonitor creates a monitor. config is read on every check so settings
+// changes apply without a restart; onChange is called for each transition.
+func NewMonitor(store *Store, config func() MonitorConfig, onChange func(Transition)) *Monitor {
+	return &Monitor{
+		store:    store,
+		config:   config,
+		onChange: onChange,
+	}
+}
+
+// Run checks sessions until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	for {
+		interval := m.config().Interval
+		if interval <= 0 {
+			interval = 15 * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		for _, t := range m.sweep(time.Now()) {
+			m.onChange(t)
+		}
+	}
+}
+
+func (m *Monitor) sweep(now time.Time) []Transition {
+	cfg := m.config()
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	var transitions []Transition
+	for _, sess := range m.store.sessions {
+		if sess.Status == StatusEnded || sess.HasPending {
+			continue
+		}
+
+		var to Status
+		var reason string
+		quiet := now.Sub(sess.LastEventAt)
+		switch {
+		case sess.PID > 0 && !processAlive(sess.PID):
+			to, reason = StatusEnded, "process exited"
+		case cfg.StaleAfter > 0 && quiet >= cfg.StaleAfter && sess.Status != StatusStale:
+			to, reason = StatusStale, "no events for "+quiet.Truncate(time.Second).String()
+		case cfg.IdleAfter > 0 && quiet >= cfg.IdleAfter && sess.Status == StatusActive:
+			to, reason = StatusIdle, "no events for "+quiet.Truncate(time.Second).String()
+		default:
+			continue
+		}
+
+		transitions = append(transitions, Transition{
+			SessionID: sess.ID,
+			From:      sess.Status,
+			To:        to,
+			Reason:    reason,
+		})
+		sess.Status = to
+		if to == StatusEnded {
+			stopSubagents(sess, now)
+		}
+	}
+	return transitions
+}
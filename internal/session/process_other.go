@@ -0,0 +1,9 @@
+//go:build !linux
+
+package session
+
+// processAlive always reports true off Linux, where process checks are not
+// supported; those sessions rely on event timeouts alone.
+func processAlive(pid int) bool {
+	return true
+}
@@ -10,18 +10,48 @@ type Status string
 const (
 	StatusActive Status = "active"
 	StatusIdle   Status = "idle"
+	StatusStale  Status = "stale"
 	StatusEnded  Status = "ended"
 )
 
+// Permission modes reported by Claude Code in hook input.
+const (
+	ModeDefault           = "default"
+	ModePlan              = "plan"
+	ModeAcceptEdits       = "acceptEdits"
+	ModeBypassPermissions = "bypassPermissions"
+)
+
+// ModeChange records a session switching permission mode.
+type ModeChange struct {
+	Mode string    `json:"mode"`
+	At   time.Time `json:"at"`
+}
+
 type Session struct {
-	ID             string    `json:"id"`
-	ProjectDir     string    `json:"project_dir"`
-	Nickname       string    `json:"nickname"`
-	Status         Status    `json:"status"`
-	StartedAt      time.Time `json:"started_at"`
-	LastEventAt    time.Time `json:"last_event_at"`
-	HasPending     bool      `json:"has_pending"`
-	PendingCount   int       `json:"pending_count"`
+	ID           string      `json:"id"`
+	ProjectDir   string      `json:"project_dir"`
+	Project      string      `json:"project"`
+	Nickname     string      `json:"nickname"`
+	Status       Status      `json:"status"`
+	StartedAt    time.Time   `json:"started_at"`
+	LastEventAt  time.Time   `json:"last_event_at"`
+	HasPending   bool        `json:"has_pending"`
+	PendingCount int         `json:"pending_count"`
+	Subagents    []*Subagent `json:"subagents,omitempty"`
+	// PID is the Claude Code process ID reported by the hook client, if any.
+	PID int `json:"pid,omitempty"`
+	// PermissionMode is the most recently reported permission mode, and
+	// ModeHistory every change to it, oldest first.
+	PermissionMode string       `json:"permission_mode,omitempty"`
+	ModeHistory    []ModeChange `json:"mode_history,omitempty"`
+	// Source is how the session last started: startup, resume, clear or
+	// compact.
+	Source         string `json:"source,omitempty"`
+	TranscriptPath string `json:"transcript_path,omitempty"`
+	// PreviousID is the session this one continues, if it was resumed,
+	// cleared or compacted from an earlier session.
+	PreviousID string `json:"previous_id,omitempty"`
 }
 
 type Store struct {
@@ -76,6 +106,52 @@ func (s *Store) TouchSession(id string) {
 	}
 }
 
+// RenameInherited updates the nickname of sessions in project that still
+// carry the project's old name.
+func (s *Store) RenameInherited(project, oldName, newName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sess := range s.sessions {
+		if sess.Project == project && sess.Nickname == oldName {
+			sess.Nickname = newName
+		}
+	}
+}
+
+// SetNickname sets a session's nickname.
+func (s *Store) SetNickname(id, nickname string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[id]; ok {
+		sess.Nickname = nickname
+	}
+}
+
+// SetPID records the Claude Code process ID for a session.
+func (s *Store) SetPID(id string, pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[id]; ok {
+		sess.PID = pid
+	}
+}
+
+// SetPermissionMode records the permission mode reported for a session. It
+// returns the previous mode and whether the mode changed; an empty mode is
+// ignored.
+func (s *Store) SetPermissionMode(id, mode string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || mode == "" || mode == sess.PermissionMode {
+		return "", false
+	}
+	previous := sess.PermissionMode
+	sess.PermissionMode = mode
+	sess.ModeHistory = append(sess.ModeHistory, ModeChange{Mode: mode, At: time.Now()})
+	return previous, true
+}
+
 func (s *Store) UpdatePending(id string, hasPending bool, count int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
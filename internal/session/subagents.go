@@ -0,0 +1,168 @@
+package session
+
+import "time"
+
+// Subagent is a Task/Agent tool invocation running under a session.
+type Subagent struct {
+	// ID is the tool_use_id of the Task call that launched the subagent, or
+	// the agent ID when the subagent was only seen through its own hooks.
+	ID          string    `json:"id"`
+	AgentID     string    `json:"agent_id,omitempty"`
+	Type        string    `json:"type,omitempty"`
+	Description string    `json:"description,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	StoppedAt   time.Time `json:"stopped_at,omitzero"`
+}
+
+func (a *Subagent) Running() bool {
+	return a.StoppedAt.IsZero()
+}
+
+// StartSubagent records a subagent as running. Starting an ID that is
+// already known is a no-op.
+func (s *Store) StartSubagent(sessionID string, agent Subagent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return
+	}
+	for _, a := range sess.Subagents {
+		if a.ID == agent.ID {
+			return
+		}
+	}
+	if agent.StartedAt.IsZero() {
+		agent.StartedAt = time.Now()
+	}
+	sess.Subagents = append(sess.Subagents, &agent)
+}
+
+// BindSubagent associates a Claude Code agent ID with a running subagent.
+// It binds to the oldest running subagent without an agent ID (matching
+// agentType if given), or starts a new one if there is none.
+func (s *Store) BindSubagent(sessionID, agentID, agentType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[sessionID]
+	if !ok || agentID == "" {
+		return
+	}
+	if findAgent(sess, agentID) != nil {
+		return
+	}
+	for _, a := range sess.Subagents {
+		if a.Running() && a.AgentID == "" && (agentType == "" || a.Type == "" || a.Type == agentType) {
+			a.AgentID = agentID
+			if a.Type == "" {
+				a.Type = agentType
+			}
+			return
+		}
+	}
+	sess.Subagents = append(sess.Subagents, &Subagent{
+		ID:        agentID,
+		AgentID:   agentID,
+		Type:      agentType,
+		StartedAt: time.Now(),
+	})
+}
+
+// StopSubagent marks a subagent stopped. id may be a subagent ID or a Claude
+// Code agent ID. With an empty id, the only running subagent is stopped; if
+// several are running the stop is ambiguous and nothing changes.
+func (s *Store) StopSubagent(sessionID, id string) (*Subagent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, false
+	}
+	var agent *Subagent
+	if id != "" {
+		agent = findAgent(sess, id)
+	} else {
+		agent = onlyRunning(sess)
+	}
+	if agent == nil || !agent.Running() {
+		return nil, false
+	}
+	agent.StoppedAt = time.Now()
+	copied := *agent
+	return &copied, true
+}
+
+// StopAllSubagents marks every running subagent stopped, e.g. when the
+// parent session stops.
+func (s *Store) StopAllSubagents(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[sessionID]; ok {
+		stopSubagents(sess, time.Now())
+	}
+}
+
+func stopSubagents(sess *Session, now time.Time) {
+	for _, a := range sess.Subagents {
+		if a.Running() {
+			a.StoppedAt = now
+		}
+	}
+}
+
+// AttributeSubagent returns the subagent a tool call belongs to: the one
+// with the given agent ID, or else the only running subagent. It returns ""
+// when the call belongs to the parent or attribution is ambiguous.
+func (s *Store) AttributeSubagent(sessionID, agentID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return ""
+	}
+	if agentID != "" {
+		if a := findAgent(sess, agentID); a != nil {
+			return a.ID
+		}
+		return ""
+	}
+	if a := onlyRunning(sess); a != nil {
+		return a.ID
+	}
+	return ""
+}
+
+// Subagents returns a copy of the session's subagents keyed by ID.
+func (s *Store) Subagents(sessionID string) map[string]Subagent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]Subagent)
+	if sess, ok := s.sessions[sessionID]; ok {
+		for _, a := range sess.Subagents {
+			result[a.ID] = *a
+		}
+	}
+	return result
+}
+
+func findAgent(sess *Session, id string) *Subagent {
+	for _, a := range sess.Subagents {
+		if a.ID == id || a.AgentID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+func onlyRunning(sess *Session) *Subagent {
+	var found *Subagent
+	for _, a := range sess.Subagents {
+		if a.Running() {
+			if found != nil {
+				return nil
+			}
+			found = a
+		}
+	}
+	return found
+}
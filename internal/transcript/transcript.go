@@ -0,0 +1,42 @@
+// Package transcript reads Claude Code transcripts as they grow.
+package transcript
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+)
+
+// ReadFrom calls fn with each complete line appended to the transcript at
+// path after offset, and returns the offset to continue from. A partial
+// last line is still being written and is left for the next call. A file
+// shorter than offset was replaced and is read from the start. On error,
+// the returned offset is past the lines already handed to fn.
+func ReadFrom(path string, offset int64, fn func(line []byte)) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return offset, err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() < offset {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		offset += int64(len(line))
+		fn(line)
+	}
+}
@@ -0,0 +1,68 @@
+package usage
+
+import (
+	"maps"
+	"strings"
+)
+
+// Price is the cost of a model in US dollars per million tokens.
+type Price struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cache_write"`
+	CacheRead  float64 `json:"cache_read"`
+}
+
+// PriceTable maps model name prefixes to prices. The longest matching
+// prefix wins, so "claude-opus-4-5" can be priced apart from
+// "claude-opus-4".
+type PriceTable map[string]Price
+
+// DefaultPrices are the published API list prices at the time of writing.
+// Override or extend them with the "prices" setting.
+var DefaultPrices = PriceTable{
+	"claude-opus-4-5":   {Input: 5, Output: 25, CacheWrite: 6.25, CacheRead: 0.50},
+	"claude-opus-4":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+	"claude-sonnet-4":   {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"claude-3-7-sonnet": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	"claude-haiku-4-5":  {Input: 1, Output: 5, CacheWrite: 1.25, CacheRead: 0.10},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+}
+
+// WithOverrides returns the table with overrides added or replacing
+// entries.
+func (t PriceTable) WithOverrides(overrides map[string]Price) PriceTable {
+	merged := maps.Clone(t)
+	maps.Copy(merged, overrides)
+	return merged
+}
+
+// Lookup returns the price for model by longest prefix match.
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	var best string
+	for prefix := range t {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return t[best], true
+}
+
+// Cost prices token usage by model. Models without a price cost nothing.
+func (t PriceTable) Cost(byModel map[string]Tokens) float64 {
+	var total float64
+	for model, tokens := range byModel {
+		price, ok := t.Lookup(model)
+		if !ok {
+			continue
+		}
+		total += (float64(tokens.Input)*price.Input +
+			float64(tokens.Output)*price.Output +
+			float64(tokens.CacheCreation)*price.CacheWrite +
+			float64(tokens.CacheRead)*price.CacheRead) / 1e6
+	}
+	return total
+}
@@ -0,0 +1,272 @@
+// Package usage reads token usage from Claude Code transcripts and prices
+// it.
+package usage
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aliadnani/claudehaus/internal/transcript"
+)
+
+// Tokens counts the tokens of one or more API responses.
+type Tokens struct {
+	Input         int64 `json:"input"`
+	Output        int64 `json:"output"`
+	CacheCreation int64 `json:"cache_creation"`
+	CacheRead     int64 `json:"cache_read"`
+}
+
+func (t *Tokens) Add(o Tokens) {
+	t.Input += o.Input
+	t.Output += o.Output
+	t.CacheCreation += o.CacheCreation
+	t.CacheRead += o.CacheRead
+}
+
+// minus returns the change from o to t.
+func (t Tokens) minus(o Tokens) Tokens {
+	return Tokens{
+		Input:         t.Input - o.Input,
+		Output:        t.Output - o.Output,
+		CacheCreation: t.CacheCreation - o.CacheCreation,
+		CacheRead:     t.CacheRead - o.CacheRead,
+	}
+}
+
+// Total is the number of tokens of all kinds.
+func (t Tokens) Total() int64 {
+	return t.Input + t.Output + t.CacheCreation + t.CacheRead
+}
+
+// Day is usage for one project on one local calendar day.
+type Day struct {
+	Date    string            `json:"date"`
+	Project string            `json:"project"`
+	Models  map[string]Tokens `json:"models"`
+}
+
+// message is the usage of one API response. Claude Code writes a
+// transcript line per content block, each repeating the message's usage,
+// so messages are keyed by ID and the last line wins.
+type message struct {
+	sessionID string
+	project   string
+	model     string
+	date      string
+	tokens    Tokens
+}
+
+// transcriptState is how far a session's transcript has been read.
+type transcriptState struct {
+	sessionID string
+	offset    int64
+}
+
+// dayKey identifies a project's usage on one day.
+type dayKey struct {
+	date    string
+	project string
+}
+
+// Tracker incrementally reads transcripts and aggregates their usage.
+// Totals by session, project and day are kept as messages are read, so
+// reading them doesn't depend on how many messages there were.
+type Tracker struct {
+	mu          sync.Mutex
+	transcripts map[string]*transcriptState
+	// messages are the responses of sessions that haven't ended, which
+	// may still be repeated or updated. See Forget.
+	messages map[string]*message
+	sessions map[string]map[string]Tokens
+	projects map[string]map[string]Tokens
+	days     map[dayKey]map[string]Tokens
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		transcripts: make(map[string]*transcriptState),
+		messages:    make(map[string]*message),
+		sessions:    make(map[string]map[string]Tokens),
+		projects:    make(map[string]map[string]Tokens),
+		days:        make(map[dayKey]map[string]Tokens),
+	}
+}
+
+// transcriptLine is the part of a transcript line carrying usage.
+type transcriptLine struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   struct {
+		ID    string `json:"id"`
+		Model string `json:"model"`
+		Usage *struct {
+			InputTokens              int64 `json:"input_tokens"`
+			OutputTokens             int64 `json:"output_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// Update reads lines appended to a session's transcript since the last
+// call. It reports whether any usage was found. Unseen usage from before
+// since is history a resumed session replays from an earlier one and is
+// skipped; a zero since keeps everything.
+func (t *Tracker) Update(sessionID, project, path string, since time.Time) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tr, ok := t.transcripts[path]
+	if !ok {
+		tr = &transcriptState{}
+		t.transcripts[path] = tr
+	}
+	tr.sessionID = sessionID
+
+	changed := false
+	offset, err := transcript.ReadFrom(path, tr.offset, func(line []byte) {
+		if !bytes.Contains(line, []byte(`"usage"`)) {
+			return
+		}
+		var entry transcriptLine
+		if json.Unmarshal(line, &entry) != nil || entry.Type != "assistant" || entry.Message.Usage == nil || entry.Message.ID == "" {
+			return
+		}
+		u := entry.Message.Usage
+		tokens := Tokens{
+			Input:         u.InputTokens,
+			Output:        u.OutputTokens,
+			CacheCreation: u.CacheCreationInputTokens,
+			CacheRead:     u.CacheReadInputTokens,
+		}
+		if prev, ok := t.messages[entry.Message.ID]; ok {
+			// Resumed sessions replay earlier messages; they stay with the
+			// session that first reported them.
+			if prev.tokens != tokens {
+				t.count(prev, tokens.minus(prev.tokens))
+				prev.tokens = tokens
+				changed = true
+			}
+			return
+		}
+		if entry.Timestamp.Before(since) {
+			return
+		}
+		m := &message{
+			sessionID: sessionID,
+			project:   project,
+			model:     entry.Message.Model,
+			date:      entry.Timestamp.Local().Format(time.DateOnly),
+			tokens:    tokens,
+		}
+		t.messages[entry.Message.ID] = m
+		t.count(m, tokens)
+		changed = true
+	})
+	tr.offset = offset
+	return changed, err
+}
+
+// count adds a change in a message's usage to the totals.
+func (t *Tracker) count(m *message, delta Tokens) {
+	addTokens(t.sessions, m.sessionID, m.model, delta)
+	addTokens(t.projects, m.project, m.model, delta)
+	addTokens(t.days, dayKey{m.date, m.project}, m.model, delta)
+}
+
+func addTokens[K comparable](totals map[K]map[string]Tokens, key K, model string, delta Tokens) {
+	byModel, ok := totals[key]
+	if !ok {
+		byModel = make(map[string]Tokens)
+		totals[key] = byModel
+	}
+	tokens := byModel[model]
+	tokens.Add(delta)
+	byModel[model] = tokens
+}
+
+// copyTokens returns a copy of usage by model that callers may keep.
+func copyTokens(byModel map[string]Tokens) map[string]Tokens {
+	result := make(map[string]Tokens, len(byModel))
+	maps.Copy(result, byModel)
+	return result
+}
+
+// Forget drops the messages and read positions of a session that has
+// ended. Its totals are kept, but usage it reports from now on counts as
+// new.
+func (t *Tracker) Forget(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, m := range t.messages {
+		if m.sessionID == sessionID {
+			delete(t.messages, id)
+		}
+	}
+	for path, tr := range t.transcripts {
+		if tr.sessionID == sessionID {
+			delete(t.transcripts, path)
+		}
+	}
+}
+
+// Session returns a session's usage by model.
+func (t *Tracker) Session(sessionID string) map[string]Tokens {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return copyTokens(t.sessions[sessionID])
+}
+
+// Project returns a project's usage by model.
+func (t *Tracker) Project(project string) map[string]Tokens {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return copyTokens(t.projects[project])
+}
+
+// Date returns usage across all projects on a local date (YYYY-MM-DD).
+func (t *Tracker) Date(date string) map[string]Tokens {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[string]Tokens)
+	for k, byModel := range t.days {
+		if k.date != date {
+			continue
+		}
+		for model, tokens := range byModel {
+			total := result[model]
+			total.Add(tokens)
+			result[model] = total
+		}
+	}
+	return result
+}
+
+// Daily returns usage per day and project on or after since (YYYY-MM-DD),
+// newest day first. An empty project matches every project.
+func (t *Tracker) Daily(since, project string) []Day {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	days := make([]Day, 0)
+	for k, byModel := range t.days {
+		if k.date < since || (project != "" && k.project != project) {
+			continue
+		}
+		days = append(days, Day{Date: k.date, Project: k.project, Models: copyTokens(byModel)})
+	}
+	slices.SortFunc(days, func(a, b Day) int {
+		if a.Date != b.Date {
+			return cmp.Compare(b.Date, a.Date)
+		}
+		return cmp.Compare(a.Project, b.Project)
+	})
+	return days
+}
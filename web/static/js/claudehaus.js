@@ -7,6 +7,9 @@
     let isAuthenticated = false;
     const STORAGE_KEY = 'claudehaus_token';
 
+    // Events per page of the session event feed, as served.
+    const FEED_PAGE_SIZE = 50;
+
     // ================================================================
     // THEME
     // ================================================================
@@ -136,6 +139,10 @@
         ws = new WebSocket(`${protocol}//${window.location.host}/ws?token=${token}`);
 
         ws.onopen = function() {
+            // Anything sent while disconnected was missed.
+            if (reconnectAttempts > 0) {
+                resync();
+            }
             reconnectAttempts = 0;
             updateStatus('CONNECTED');
             hideLogin();
@@ -183,87 +190,91 @@
     // ================================================================
     function handleMessage(msg) {
         switch (msg.type) {
-            case 'event':
-                htmx.trigger(document.body, 'refresh');
+            case 'fragment':
+                swapFragment(msg);
                 break;
-            case 'approval_request':
-                htmx.trigger('#sessions', 'refresh');
-                htmx.trigger(document.body, 'refresh');
+            case 'session_update':
+                // Status changes reorder the list.
+                htmx.trigger(document.body, 'refresh-sessions');
+                if (!msg.session_id || msg.session_id === detailSessionId()) {
+                    htmx.trigger(document.body, 'refresh-detail');
+                }
                 break;
-            case 'approval_resolved':
-                htmx.trigger(document.body, 'refresh');
+            case 'subagent_update':
+            case 'git_update':
+                if (msg.session_id === detailSessionId()) {
+                    htmx.trigger(document.body, 'refresh-detail');
+                }
                 break;
-            case 'session_update':
-                htmx.trigger('#sessions', 'refresh');
+            case 'usage_update':
+                // A session's usage is pushed as fragments; budget
+                // changes affect every session.
+                if (!msg.session_id) {
+                    htmx.trigger(document.body, 'refresh');
+                }
+                break;
+            case 'resync':
+                // The server dropped messages while we were behind.
+                resync();
                 break;
             case 'notification':
                 handleNotification(msg);
                 break;
+            case 'alert':
+                handleAlert(msg);
+                break;
         }
     }
 
-    // ================================================================
-    // MULTI-CHOICE APPROVALS
-    // ================================================================
-    function parseMultiChoicePrompts() {
-        const prompts = document.querySelectorAll('.approval-prompt');
-        prompts.forEach(promptEl => {
-            const promptText = promptEl.textContent;
-            const choices = parseChoices(promptText);
-            if (choices.length > 0) {
-                updateApprovalButtons(promptEl.closest('.approval-card'), choices);
-            }
-        });
+    function resync() {
+        htmx.trigger(document.body, 'refresh');
     }
 
-    function parseChoices(promptText) {
-        const choices = [];
-        const lines = promptText.split('\n');
-        const choicePattern = /\[([^\]]+)\]\s*(.+)/;
+    function detailSessionId() {
+        const detail = document.getElementById('session-detail-content');
+        return detail ? detail.dataset.sessionId : null;
+    }
 
-        lines.forEach(line => {
-            const match = line.match(choicePattern);
-            if (match) {
-                const key = match[1].trim();
-                const label = match[2].trim();
-                if (!/^\d{2}:\d{2}:\d{2}$/.test(key) && key.toLowerCase() !== 'true' && key.toLowerCase() !== 'false') {
-                    choices.push({ key, label });
+    // New events, approval cards and session list items arrive as
+    // server-rendered out-of-band swaps. Swaps whose target isn't on the
+    // page are dropped, and rows already shown are skipped. Where the page
+    // should have had the target, such as a new session in the list or the
+    // first event of the open session, that view is refreshed instead.
+    function swapFragment(msg) {
+        const template = document.createElement('template');
+        template.innerHTML = msg.data.html;
+        const refreshes = new Set();
+        template.content.querySelectorAll('[hx-swap-oob]').forEach(function(el) {
+            const oob = el.getAttribute('hx-swap-oob');
+            const colon = oob.indexOf(':');
+            const target = colon > 0 ? oob.slice(colon + 1) : '#' + el.id;
+            let found = null;
+            try {
+                found = document.querySelector(target);
+            } catch (e) {}
+            if (!found) {
+                const fallback = el.dataset.fallback;
+                if (fallback === 'sessions' || (fallback === 'detail' && msg.session_id === detailSessionId())) {
+                    refreshes.add('refresh-' + fallback);
                 }
+                el.remove();
+                return;
+            }
+            if (oob.startsWith('afterbegin:') || oob.startsWith('beforeend:')) {
+                Array.from(el.children).forEach(function(child) {
+                    if (child.id && document.getElementById(child.id)) child.remove();
+                });
+                if (!el.querySelector('.event-item, .prompt-header, .approval-card')) el.remove();
             }
         });
-
-        return choices;
-    }
-
-    function updateApprovalButtons(approvalCard, choices) {
-        const actionsDiv = approvalCard.querySelector('.approval-actions');
-        if (!actionsDiv) return;
-
-        actionsDiv.innerHTML = '';
-
-        choices.forEach(choice => {
-            const btn = document.createElement('button');
-            btn.className = 'btn btn-choice';
-            btn.textContent = '[' + choice.key + '] ' + choice.label;
-            btn.dataset.decision = choice.key;
-            btn.onclick = () => submitDecision(approvalCard, choice.key);
-            actionsDiv.appendChild(btn);
-        });
-    }
-
-    function submitDecision(approvalCard, decision) {
-        const approvalId = approvalCard.dataset.approvalId;
-        if (!approvalId) return;
-
-        fetch('/api/approvals/' + approvalId, {
-            method: 'POST',
-            headers: {
-                'Content-Type': 'application/json',
-                'Authorization': 'Bearer ' + (localStorage.getItem(STORAGE_KEY) || '')
-            },
-            body: JSON.stringify({ decision: decision })
-        }).then(() => {
-            htmx.trigger(document.body, 'refresh');
+        if (template.content.querySelector('[hx-swap-oob]')) {
+            htmx.swap(document.body, template.innerHTML, {swapStyle: 'none'});
+            updateSessionTimers();
+            updateEventTimes();
+            restoreOpenDetails();
+        }
+        refreshes.forEach(function(name) {
+            htmx.trigger(document.body, name);
         });
     }
 
@@ -276,24 +287,32 @@
             return;
         }
 
-        const container = document.querySelector('.notification-container');
-        if (!container) return;
-
-        const toast = document.createElement('div');
         const type = msg.data.type || 'info';
         const label = type === 'idle_prompt' ? 'Waiting for input' : type.replace(/_/g, ' ');
+        showToast('#notifications', type, label, msg.data.message, type !== 'idle_prompt');
+    }
+
+    // Alerts are shown whichever session is open and stay until dismissed.
+    function handleAlert(msg) {
+        showToast('#alerts', msg.data.type, msg.data.type.replace(/_/g, ' '), msg.data.message, false);
+    }
+
+    function showToast(containerSelector, type, label, message, autoDismiss) {
+        const container = document.querySelector(containerSelector);
+        if (!container) return;
 
+        const toast = document.createElement('div');
         toast.className = 'notification-toast ' + type;
         toast.innerHTML =
             '<div class="notification-header">' +
                 '<span>' + escapeHtml(label) + '</span>' +
                 '<button class="notification-close" onclick="dismissNotification(this)">&times;</button>' +
             '</div>' +
-            '<div class="notification-message">' + escapeHtml(msg.data.message || '') + '</div>';
+            '<div class="notification-message">' + escapeHtml(message || '') + '</div>';
 
         container.appendChild(toast);
 
-        if (type !== 'idle_prompt') {
+        if (autoDismiss) {
             setTimeout(() => {
                 dismissNotification(toast.querySelector('.notification-close'));
             }, 5000);
@@ -347,6 +366,88 @@
         toggleEventDetails(element);
     }
 
+    // Large tool inputs and responses are previewed; fetch the full
+    // content when asked.
+    function loadPayload(button, url) {
+        const token = localStorage.getItem(STORAGE_KEY);
+        button.disabled = true;
+        fetch(url, {headers: token ? {'Authorization': 'Bearer ' + token} : {}})
+            .then(resp => {
+                if (!resp.ok) throw new Error(resp.statusText);
+                return resp.json();
+            })
+            .then(payload => {
+                const container = button.parentElement;
+                const input = container.querySelector('.event-tool-input, .approval-command');
+                const response = container.querySelector('.event-tool-response');
+                if (input) input.textContent = payload.tool_input;
+                if (response) response.textContent = payload.tool_response || '';
+                button.textContent = payload.clipped ? 'Shown up to the size limit' : 'Showing full content';
+            })
+            .catch(err => {
+                button.disabled = false;
+                button.textContent = 'Failed to load: ' + err.message;
+            });
+    }
+
+    // ================================================================
+    // COLLAPSIBLE SECTIONS
+    // ================================================================
+    // <details> elements lose their open state when the session detail is
+    // re-rendered, so remember which ones the user opened.
+    const openDetails = new Set();
+
+    document.addEventListener('toggle', function(e) {
+        const el = e.target;
+        if (el.tagName !== 'DETAILS' || !el.id) return;
+        if (el.open) {
+            openDetails.add(el.id);
+        } else {
+            openDetails.delete(el.id);
+        }
+    }, true);
+
+    function restoreOpenDetails() {
+        openDetails.forEach(function(id) {
+            const el = document.getElementById(id);
+            if (el) el.open = true;
+        });
+    }
+
+    // ================================================================
+    // DETAIL TABS
+    // ================================================================
+    let activeDetailTab = 'events';
+
+    function switchDetailTab(tab) {
+        activeDetailTab = tab;
+        restoreDetailTab();
+    }
+
+    function restoreDetailTab() {
+        document.querySelectorAll('.detail-tab').forEach(function(el) {
+            el.classList.toggle('active', el.dataset.tab === activeDetailTab);
+        });
+        document.querySelectorAll('.detail-pane').forEach(function(el) {
+            el.classList.toggle('active', el.dataset.pane === activeDetailTab);
+        });
+    }
+
+    // showEvent switches to the event feed and highlights an event.
+    function showEvent(id) {
+        switchDetailTab('events');
+        const el = document.getElementById('event-' + id);
+        if (!el) return;
+        const block = el.closest('details');
+        if (block) block.open = true;
+        document.querySelectorAll('.event-item.active').forEach(e => e.classList.remove('active'));
+        el.classList.add('active');
+        el.scrollIntoView({ block: 'center' });
+    }
+
+    window.switchDetailTab = switchDetailTab;
+    window.showEvent = showEvent;
+
     // ================================================================
     // KEYBOARD SHORTCUTS
     // ================================================================
@@ -442,7 +543,7 @@
     }
 
     function focusSearch() {
-        const search = document.querySelector('input[type="search"]');
+        const search = document.getElementById('global-search') || document.querySelector('input[type="search"]');
         if (search) search.focus();
     }
 
@@ -468,6 +569,7 @@
     window.hideHelp = hideHelp;
     window.toggleEventDetails = toggleEventDetails;
     window.handleEventClick = handleEventClick;
+    window.loadPayload = loadPayload;
 
     // ================================================================
     // SESSION TIMERS
@@ -491,6 +593,37 @@
         });
     }
 
+    // Event times show the browser's local clock time, or time relative to
+    // now, toggled from the event feed header.
+    const TIME_MODE_KEY = 'claudehaus_time_mode';
+
+    function updateEventTimes() {
+        const relative = localStorage.getItem(TIME_MODE_KEY) === 'relative';
+        document.querySelectorAll('time.event-time[datetime]').forEach(function(el) {
+            const at = new Date(el.getAttribute('datetime'));
+            if (isNaN(at.getTime())) return;
+            el.textContent = relative
+                ? formatSecondsAgo(Math.floor(at.getTime() / 1000))
+                : at.toLocaleTimeString([], { hour12: false });
+            el.title = at.toLocaleString();
+        });
+    }
+
+    window.toggleTimeMode = function() {
+        const relative = localStorage.getItem(TIME_MODE_KEY) === 'relative';
+        localStorage.setItem(TIME_MODE_KEY, relative ? 'clock' : 'relative');
+        updateEventTimes();
+    };
+
+    function updateHoldTimers() {
+        document.querySelectorAll('.approval-timeout[data-expires]').forEach(function(el) {
+            const expires = parseInt(el.dataset.expires, 10);
+            if (!expires) return;
+            const secs = expires - Math.floor(Date.now() / 1000);
+            el.textContent = secs > 0 ? 'Window closes in ' + secs + 's' : 'Window closed';
+        });
+    }
+
     // ================================================================
     // INIT
     // ================================================================
@@ -501,22 +634,32 @@
         checkAuth();
 
         setInterval(updateSessionTimers, 1000);
+        setInterval(updateHoldTimers, 1000);
+        setInterval(updateEventTimes, 1000);
 
         document.body.addEventListener('htmx:configRequest', function(evt) {
             const token = localStorage.getItem(STORAGE_KEY);
             if (token) {
                 evt.detail.headers['Authorization'] = 'Bearer ' + token;
             }
+            // Keep the older pages already scrolled into the event feed
+            // when the session view refreshes.
+            if (evt.detail.elt.id === 'session-detail-content') {
+                const loaded = evt.detail.elt.querySelectorAll('.event-feed .event-item, .event-feed .prompt-header').length;
+                if (loaded > FEED_PAGE_SIZE) {
+                    evt.detail.parameters['limit'] = loaded;
+                }
+            }
         });
 
         document.body.addEventListener('htmx:afterSwap', function(evt) {
-            parseMultiChoicePrompts();
             updateSessionTimers();
+            updateEventTimes();
+            restoreOpenDetails();
+            restoreDetailTab();
             if (evt.detail.target.id === 'session-detail' && window.innerWidth <= 768) {
                 document.querySelector('.layout').classList.add('mobile-detail');
             }
         });
-
-        parseMultiChoicePrompts();
     });
 })();